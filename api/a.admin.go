@@ -0,0 +1,16 @@
+package api
+
+import (
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get RPC backends
+// @Description Health of the RPC backends: reachable, synced height, mempool size and which one is active
+// @Tags admin
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} client.BackendStatus
+// @Router /admin/backends [get]
+func (a *Api) Backends(c *fiber.Ctx) error {
+	return apiSuccess(c, a.core.GetBackends())
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/1F47E/go-feesh/logger"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get recent blocks
+// @Description Stats of the last parsed blocks, new first
+// @Tags blocks
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} block.Block
+// @Failure 500 {object} APIError
+// @Router /blocks [get]
+func (a *Api) Blocks(c *fiber.Ctx) error {
+	blocks := a.core.GetBlocks()
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Height > blocks[j].Height
+	})
+	return apiSuccess(c, blocks)
+}
+
+// @Summary Get block
+// @Description Block stats by hash
+// @Tags blocks
+// @Accept  json
+// @Produce  json
+// @Param hash path string true "Block hash"
+// @Success 200 {object} block.Block
+// @Failure 404 {object} APIError
+// @Failure 500 {object} APIError
+// @Router /block/{hash} [get]
+func (a *Api) Block(c *fiber.Ctx) error {
+	log := c.Locals("logger").(logger.LoggerEntry)
+
+	b, err := a.core.GetBlock(c.Params("hash"))
+	if err != nil {
+		log.Errorf("error on getblock: %v\n", err)
+		return apiError(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+	if b == nil {
+		return apiError(c, http.StatusNotFound, "block not found")
+	}
+	return apiSuccess(c, b)
+}
+
+// @Summary Get backfill status
+// @Description Historical blocks backfill progress with the current height and ETA
+// @Tags blocks
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} block.Backfill
+// @Failure 404 {object} APIError
+// @Router /backfill [get]
+func (a *Api) Backfill(c *fiber.Ctx) error {
+	p := a.core.GetBackfill()
+	if p == nil {
+		return apiError(c, http.StatusNotFound, "backfill is not configured")
+	}
+	return apiSuccess(c, p)
+}
+
+// @Summary Get recent blocks health
+// @Description Mined blocks compared against the projected template, new first
+// @Tags blocks
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Limit the number of blocks returned"
+// @Success 200 {array} block.Health
+// @Failure 400 {object} APIError
+// @Failure 500 {object} APIError
+// @Router /blocks/health [get]
+func (a *Api) BlocksHealth(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 {
+		return apiError(c, http.StatusBadRequest, "limit must be positive")
+	}
+	return apiSuccess(c, a.core.GetBlocksHealth(limit))
+}
+
+// @Summary Get block health
+// @Description Match percentage with the projected template, missing and unexpected txs
+// @Tags blocks
+// @Accept  json
+// @Produce  json
+// @Param hash path string true "Block hash"
+// @Success 200 {object} block.Health
+// @Failure 404 {object} APIError
+// @Failure 500 {object} APIError
+// @Router /block/{hash}/health [get]
+func (a *Api) BlockHealth(c *fiber.Ctx) error {
+	log := c.Locals("logger").(logger.LoggerEntry)
+
+	h, err := a.core.GetBlockHealth(c.Params("hash"))
+	if err != nil {
+		log.Errorf("error on getblockhealth: %v\n", err)
+		return apiError(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+	if h == nil {
+		return apiError(c, http.StatusNotFound, "block health not found")
+	}
+	return apiSuccess(c, h)
+}
@@ -26,7 +26,7 @@ func (a *Api) Version(c *fiber.Ctx) error {
 
 func (a *Api) NodeInfo(c *fiber.Ctx) error {
 	// txs := a.core.GetPoolTxs()
-	info, err := a.core.GetNodeInfo()
+	info, err := a.core.GetNodeInfo(c.UserContext())
 	if err != nil {
 		return c.Status(500).SendString(err.Error())
 	}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get recommended fees
+// @Description Recommended fee rates in sat/vB for next block, ~30 min, ~1 hour and economy targets
+// @Tags fees
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} fee.Recommended
+// @Failure 500 {object} APIError
+// @Router /fees/recommended [get]
+func (a *Api) FeesRecommended(c *fiber.Ctx) error {
+	return apiSuccess(c, a.core.GetFeeRecommended())
+}
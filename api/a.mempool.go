@@ -0,0 +1,17 @@
+package api
+
+import (
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get projected blocks
+// @Description Next block templates built from the pool by ancestor fee rate
+// @Tags pool
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} block.Projected
+// @Failure 500 {object} APIError
+// @Router /mempool/blocks [get]
+func (a *Api) MempoolBlocks(c *fiber.Ctx) error {
+	return apiSuccess(c, a.core.GetProjectedBlocks())
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get tx first seen
+// @Description When and from which peer the tx was announced first, p2p mode only
+// @Tags pool
+// @Accept  json
+// @Produce  json
+// @Param txid path string true "Transaction id"
+// @Success 200 {object} p2p.Seen
+// @Failure 404 {object} APIError
+// @Router /tx/{txid}/seen [get]
+func (a *Api) TxSeen(c *fiber.Ctx) error {
+	seen, ok := a.core.GetTxFirstSeen(c.Params("txid"))
+	if !ok {
+		return apiError(c, http.StatusNotFound, "tx was not seen by p2p listener")
+	}
+	return apiSuccess(c, seen)
+}
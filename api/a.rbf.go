@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get recent replacements
+// @Description Pool txs replaced by another tx spending the same outputs, new first
+// @Tags rbf
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Limit the number of replacements returned"
+// @Success 200 {array} rbf.Replacement
+// @Failure 400 {object} APIError
+// @Failure 500 {object} APIError
+// @Router /replacements [get]
+func (a *Api) Replacements(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 {
+		return apiError(c, http.StatusBadRequest, "limit must be positive")
+	}
+	return apiSuccess(c, a.core.GetReplacements(limit))
+}
+
+// @Summary Get tx replacement chain
+// @Description Replacement chain the tx belongs to, from the first original to the last replacement
+// @Tags rbf
+// @Accept  json
+// @Produce  json
+// @Param txid path string true "Transaction id"
+// @Success 200 {array} rbf.Replacement
+// @Failure 500 {object} APIError
+// @Router /tx/{txid}/rbf [get]
+func (a *Api) TxRbf(c *fiber.Ctx) error {
+	return apiSuccess(c, a.core.GetTxReplacements(c.Params("txid")))
+}
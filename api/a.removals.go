@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	mremoval "github.com/1F47E/go-feesh/entity/models/removal"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+type RemovalsResponse struct {
+	Counters map[mremoval.Reason]uint64 `json:"counters"`
+	Recent   []mremoval.Removal         `json:"recent"`
+}
+
+// @Summary Get removed txs
+// @Description Txs that left the pool with the reason: confirmed, replaced or evicted
+// @Tags pool
+// @Accept  json
+// @Produce  json
+// @Param limit query int false "Limit the number of removals returned"
+// @Success 200 {object} RemovalsResponse
+// @Failure 400 {object} APIError
+// @Failure 500 {object} APIError
+// @Router /removals [get]
+func (a *Api) Removals(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit < 1 {
+		return apiError(c, http.StatusBadRequest, "limit must be positive")
+	}
+	ret := RemovalsResponse{
+		Counters: a.core.GetRemovalCounters(),
+		Recent:   a.core.GetRemovals(limit),
+	}
+	return apiSuccess(c, ret)
+}
+
+// @Summary Get tx removal
+// @Description Why the tx left the pool
+// @Tags pool
+// @Accept  json
+// @Produce  json
+// @Param txid path string true "Transaction id"
+// @Success 200 {object} removal.Removal
+// @Failure 404 {object} APIError
+// @Router /tx/{txid}/removal [get]
+func (a *Api) TxRemoval(c *fiber.Ctx) error {
+	rm, ok := a.core.GetTxRemoval(c.Params("txid"))
+	if !ok {
+		return apiError(c, http.StatusNotFound, "tx removal not found")
+	}
+	return apiSuccess(c, rm)
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	fiber "github.com/gofiber/fiber/v2"
+)
+
+// @Summary Get confirmation times
+// @Description Percentiles of blocks and seconds waited from first seen in the pool to confirmation, per fee rate band
+// @Tags stats
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} stats.ConfirmationTimes
+// @Failure 500 {object} APIError
+// @Router /stats/confirmation-times [get]
+func (a *Api) ConfirmationTimes(c *fiber.Ctx) error {
+	return apiSuccess(c, a.core.GetConfirmationTimes())
+}
+
+// @Summary Get mining pools stats
+// @Description Blocks share and fee revenue per mining pool over the last blocks
+// @Tags stats
+// @Accept  json
+// @Produce  json
+// @Param window query int false "Number of the last blocks, default 144"
+// @Success 200 {object} stats.Miners
+// @Failure 400 {object} APIError
+// @Failure 500 {object} APIError
+// @Router /stats/miners [get]
+func (a *Api) Miners(c *fiber.Ctx) error {
+	window := c.QueryInt("window", 144)
+	if window < 1 {
+		return apiError(c, http.StatusBadRequest, "window must be positive")
+	}
+	ret, err := a.core.GetMinerStats(window)
+	if err != nil {
+		return apiError(c, http.StatusInternalServerError, "Something went wrong", err.Error())
+	}
+	return apiSuccess(c, ret)
+}
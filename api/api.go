@@ -46,10 +46,25 @@ func NewApi(core *core.Core, notificator *notificator.Notificator) *Api {
 	api.Get("/swagger/*", swagger.HandlerDefault) // default
 	api.Get("/monitor", monitor.New())
 	api.Get("/stats", a.Stats)
+	api.Get("/stats/confirmation-times", a.ConfirmationTimes)
+	api.Get("/stats/miners", a.Miners)
 	api.Get("/info", a.NodeInfo)
+	api.Get("/admin/backends", a.Backends)
 	api.Get("/ping", a.Ping)
 	api.Get("/version", a.Version)
 	api.Get("/pool", a.Pool)
+	api.Get("/fees/recommended", a.FeesRecommended)
+	api.Get("/mempool/blocks", a.MempoolBlocks)
+	api.Get("/replacements", a.Replacements)
+	api.Get("/tx/:txid/rbf", a.TxRbf)
+	api.Get("/removals", a.Removals)
+	api.Get("/tx/:txid/removal", a.TxRemoval)
+	api.Get("/tx/:txid/seen", a.TxSeen)
+	api.Get("/backfill", a.Backfill)
+	api.Get("/blocks", a.Blocks)
+	api.Get("/block/:hash", a.Block)
+	api.Get("/block/:hash/health", a.BlockHealth)
+	api.Get("/blocks/health", a.BlocksHealth)
 
 	// websockets
 	api.Get("/ws", websocket.New(func(c *websocket.Conn) {
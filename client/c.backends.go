@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/1F47E/go-feesh/logger"
+)
+
+// backend is behind by more blocks than this - not synced
+const syncTolerance = 1
+
+// status of the backend after the last health check
+type BackendStatus struct {
+	Host        string    `json:"host"` // without credentials
+	Priority    int       `json:"priority"`
+	Active      bool      `json:"active"`
+	Reachable   bool      `json:"reachable"`
+	Synced      bool      `json:"synced"`
+	Height      int       `json:"height"`
+	MempoolSize int       `json:"mempool_size"`
+	LatencyMs   int64     `json:"latency_ms"`
+	LastCheck   time.Time `json:"last_check"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+type backend struct {
+	host     string
+	user     string
+	password string
+	priority int
+
+	mu       *sync.Mutex
+	caps     capabilities
+	detected bool // caps are checked, btcd defaults till then
+	status   BackendStatus
+}
+
+func newBackend(host, user, password string, priority int) *backend {
+	return &backend{
+		host:     host,
+		user:     user,
+		password: password,
+		priority: priority,
+		mu:       &sync.Mutex{},
+		caps:     capabilities{getInfo: true, getBestBlock: true},
+		// reachable until proven otherwise
+		status: BackendStatus{Host: redact(host), Priority: priority, Reachable: true, Synced: true},
+	}
+}
+
+func (b *backend) name() string {
+	return b.status.Host
+}
+
+// false if the caps are not detected yet
+func (b *backend) getCaps() (capabilities, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.caps, b.detected
+}
+
+func (b *backend) setCaps(caps capabilities) {
+	b.mu.Lock()
+	b.caps = caps
+	b.detected = true
+	b.mu.Unlock()
+}
+
+func (b *backend) getStatus() BackendStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// host without the user and password
+func redact(host string) string {
+	u, err := url.Parse(host)
+	if err != nil || u.User == nil {
+		return host
+	}
+	u.User = nil
+	return u.String()
+}
+
+// backends by priority, lower first
+type backends struct {
+	mu     *sync.Mutex
+	list   []*backend
+	active *backend
+}
+
+func newBackends(primary *backend) *backends {
+	return &backends{
+		mu:     &sync.Mutex{},
+		list:   []*backend{primary},
+		active: primary,
+	}
+}
+
+// snapshot of the list, backends are added meanwhile
+func (bs *backends) all() []*backend {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	list := make([]*backend, len(bs.list))
+	copy(list, bs.list)
+	return list
+}
+
+func (bs *backends) current() *backend {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.active
+}
+
+// active first, then the healthy ones, then the rest by priority
+func (bs *backends) ordered() []*backend {
+	active := bs.current()
+	list := bs.all()
+	rank := func(b *backend) int {
+		if b == active {
+			return 0
+		}
+		st := b.getStatus()
+		if st.Reachable && st.Synced {
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return rank(list[i]) < rank(list[j])
+	})
+	return list
+}
+
+// mark the backend unreachable, returns true if the active one was switched
+func (bs *backends) failed(b *backend, err error) bool {
+	b.mu.Lock()
+	b.status.Reachable = false
+	b.status.LastError = err.Error()
+	b.mu.Unlock()
+	return bs.choose()
+}
+
+// pick the reachable and synced backend with the best priority
+// the active one is kept if there is none
+func (bs *backends) choose() bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	for _, b := range bs.list {
+		st := b.getStatus()
+		if !st.Reachable || !st.Synced {
+			continue
+		}
+		if b == bs.active {
+			return false
+		}
+		bs.active = b
+		return true
+	}
+	return false
+}
+
+// backup node, priority is the order of adding.
+// credentials in the host url take precedence
+func (c *Client) AddBackend(host, user, password string) {
+	if u, err := url.Parse(host); err == nil && u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+		u.User = nil
+		host = u.String()
+	}
+	bs := c.backends
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.list = append(bs.list, newBackend(host, user, password, len(bs.list)))
+}
+
+func (c *Client) Backends() []BackendStatus {
+	active := c.backends.current()
+	list := c.backends.all()
+	ret := make([]BackendStatus, 0, len(list))
+	for _, b := range list {
+		st := b.getStatus()
+		st.Active = b == active
+		ret = append(ret, st)
+	}
+	return ret
+}
+
+// check all the backends and switch to the best one
+func (c *Client) HealthCheck(ctx context.Context) {
+	l := log.Log.WithField("context", "[RPC]")
+	maxHeight := 0
+	list := c.backends.all()
+	for _, b := range list {
+		st := c.checkBackend(ctx, b)
+		if st.Reachable && st.Height > maxHeight {
+			maxHeight = st.Height
+		}
+	}
+	for _, b := range list {
+		b.mu.Lock()
+		b.status.Synced = b.status.Reachable && b.status.Height >= maxHeight-syncTolerance
+		b.mu.Unlock()
+	}
+	if c.backends.choose() {
+		l.Warnf("switched to backend %s\n", c.backends.current().name())
+	}
+}
+
+func (c *Client) checkBackend(ctx context.Context, b *backend) BackendStatus {
+	now := time.Now()
+	wasReachable := b.getStatus().Reachable
+	var height int
+	err := c.callTo(ctx, b, "getblockcount", []interface{}{}, &height)
+	var mempool struct {
+		Size int `json:"size"`
+	}
+	if err == nil {
+		err = c.callTo(ctx, b, "getmempoolinfo", []interface{}{}, &mempool)
+	}
+	// unreachable on start or restarted, can be another node now
+	if _, ok := b.getCaps(); err == nil && (!ok || !wasReachable) {
+		_, err = c.detect(ctx, b)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status.LastCheck = now
+	b.status.LatencyMs = time.Since(now).Milliseconds()
+	b.status.Reachable = err == nil
+	if err != nil {
+		b.status.LastError = err.Error()
+		return b.status
+	}
+	b.status.LastError = ""
+	b.status.Height = height
+	b.status.MempoolSize = mempool.Size
+	return b.status
+}
+
+// call to the specific backend, no failover
+func (c *Client) callTo(ctx context.Context, b *backend, method string, params []interface{}, ret interface{}) error {
+	data, err := c.doRequestTo(ctx, b, NewRPCRequest(method, params))
+	if err != nil {
+		return err
+	}
+	return unmarshalResult(method, data, ret)
+}
+
+func unmarshalResult(method string, data *RPCResponse, ret interface{}) error {
+	if data.Error != nil {
+		return fmt.Errorf("%s: %w", method, data.Error)
+	}
+	return json.Unmarshal(data.Result, ret)
+}
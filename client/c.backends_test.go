@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// node answering the methods with the canned results, the rest is method not found
+type stubNode struct {
+	*httptest.Server
+	down  int32 // 503 while set, atomic
+	mu    *sync.Mutex
+	calls map[string]int
+}
+
+func newStubNode(t *testing.T, results map[string]string) *stubNode {
+	t.Helper()
+	n := &stubNode{mu: &sync.Mutex{}, calls: make(map[string]int)}
+	n.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&n.down) == 1 {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req RPCRequest
+		_ = json.Unmarshal(body, &req)
+		n.mu.Lock()
+		n.calls[req.Method]++
+		n.mu.Unlock()
+		result, ok := results[req.Method]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":1}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":` + result + `,"error":null,"id":1}`))
+	}))
+	t.Cleanup(n.Close)
+	return n
+}
+
+func (n *stubNode) called(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+var (
+	btcdResults = map[string]string{
+		"getinfo":        `{"version":230300,"blocks":100}`,
+		"getbestblock":   `{"hash":"btcd","height":100}`,
+		"getblockcount":  `100`,
+		"getmempoolinfo": `{"size":1}`,
+	}
+	coreResults = map[string]string{
+		"getblockchaininfo": `{"chain":"testnet4","blocks":100}`,
+		"getnetworkinfo":    `{"version":270000}`,
+		"getbestblockhash":  `"core"`,
+		"getblockheader":    `{"hash":"core","height":100}`,
+		"getblockcount":     `100`,
+		"getmempoolinfo":    `{"size":1}`,
+	}
+)
+
+// btcd primary and bitcoin core backup, the method is chosen for the backend the call goes to
+func TestBackendCapsFailover(t *testing.T) {
+	ctx := context.Background()
+	btcd := newStubNode(t, btcdResults)
+	core := newStubNode(t, coreResults)
+	atomic.StoreInt32(&btcd.down, 1)
+	c, _ := NewClient(btcd.URL, "user", "pass")
+	c.AddBackend(core.URL, "user", "pass")
+	c.retries = 0
+
+	// primary is down on start
+	if err := c.Detect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	info, err := c.GetInfo(ctx)
+	if err != nil {
+		t.Fatalf("getinfo on the core backup: %v", err)
+	}
+	if info.Version != 270000 || info.Blocks != 100 || !info.Testnet {
+		t.Fatalf("info %+v, want the core one", info)
+	}
+	best, err := c.GetBestBlock(ctx)
+	if err != nil || best.Hash != "core" || best.Height != 100 {
+		t.Fatalf("best block %+v, %v, want the core one", best, err)
+	}
+	// only the detection
+	if n := core.called("getinfo"); n != 1 {
+		t.Fatalf("getinfo sent to core %d times, want 1", n)
+	}
+
+	// primary is back, detected as btcd and active again
+	atomic.StoreInt32(&btcd.down, 0)
+	c.HealthCheck(ctx)
+	if c.backends.current().host != btcd.URL {
+		t.Fatalf("active backend %s, want the primary", c.backends.current().name())
+	}
+	info, err = c.GetInfo(ctx)
+	if err != nil || info.Version != 230300 {
+		t.Fatalf("info %+v, %v, want the btcd one", info, err)
+	}
+	best, err = c.GetBestBlock(ctx)
+	if err != nil || best.Hash != "btcd" {
+		t.Fatalf("best block %+v, %v, want the btcd one", best, err)
+	}
+	if n := btcd.called("getblockchaininfo"); n != 0 {
+		t.Fatalf("stock getinfo sent to btcd %d times", n)
+	}
+}
+
+// caps are detected on the first use if the backend was never reachable for Detect
+func TestBackendCapsLazy(t *testing.T) {
+	core := newStubNode(t, coreResults)
+	c, _ := NewClient(core.URL, "user", "pass")
+	best, err := c.GetBestBlock(context.Background())
+	if err != nil || best.Hash != "core" {
+		t.Fatalf("best block %+v, %v", best, err)
+	}
+	if n := core.called("getbestblock"); n != 1 {
+		t.Fatalf("getbestblock sent %d times, want only the detection", n)
+	}
+}
+
+// backends are added while the list is read, run with -race
+func TestBackendsConcurrent(t *testing.T) {
+	node := newStubNode(t, btcdResults)
+	c, _ := NewClient(node.URL, "user", "pass")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			c.AddBackend(node.URL, "user", "pass")
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		c.Backends()
+		c.HealthCheck(context.Background())
+	}
+	wg.Wait()
+	if n := len(c.Backends()); n != 11 {
+		t.Fatalf("%d backends, want 11", n)
+	}
+}
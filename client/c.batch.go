@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+)
+
+// requests per batch for the calls split by the client
+func (c *Client) SetBatchSize(n int) {
+	if n > 0 {
+		c.batchSize = n
+	}
+}
+
+// send requests as a single JSON-RPC array.
+// responses are returned in the order of the requests, ids are overwritten
+// curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '[{"jsonrpc":"1.0","method":"getblockcount","params":[],"id":0},{"jsonrpc":"1.0","method":"getbestblockhash","params":[],"id":1}]' http://localhost:18334
+func (c *Client) Batch(ctx context.Context, reqs []*RPCRequest) ([]*RPCResponse, error) {
+	if len(reqs) == 0 {
+		return nil, nil
+	}
+	for i, r := range reqs {
+		r.Id = i
+	}
+	jr, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.post(ctx, c.timeouts.forMethod(reqs[0].Method), jr)
+	if err != nil {
+		return nil, err
+	}
+
+	var resps []*RPCResponse
+	if err := json.Unmarshal(data, &resps); err != nil {
+		// whole batch failed, node replies with a single error
+		var single RPCResponse
+		if json.Unmarshal(data, &single) == nil && single.Error != nil {
+			return nil, fmt.Errorf("batch error: %w", single.Error)
+		}
+		return nil, fmt.Errorf("error unmarshalling batch response: %v", err)
+	}
+	// responses can come in any order
+	ret := make([]*RPCResponse, len(reqs))
+	for _, resp := range resps {
+		if resp == nil || resp.Id < 0 || resp.Id >= len(reqs) {
+			continue
+		}
+		ret[resp.Id] = resp
+	}
+	return ret, nil
+}
+
+// get transactions with a single batch request.
+// errors of the single txs are returned separately, e.g. not found ones
+func (c *Client) TransactionGetMany(ctx context.Context, txids []string) (map[string]*tx.Transaction, map[string]error, error) {
+	reqs := make([]*RPCRequest, 0, len(txids))
+	for _, txid := range txids {
+		reqs = append(reqs, NewRPCRequest("getrawtransaction", []interface{}{txid, 1}))
+	}
+	resps, err := c.Batch(ctx, reqs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error on getrawtransaction batch: %w", err)
+	}
+	ret := make(map[string]*tx.Transaction, len(txids))
+	errs := make(map[string]error)
+	for i, resp := range resps {
+		txid := txids[i]
+		if resp == nil {
+			errs[txid] = fmt.Errorf("no response in batch")
+			continue
+		}
+		if resp.Error != nil {
+			errs[txid] = resp.Error
+			continue
+		}
+		var t tx.Transaction
+		if err := decodeResult(resp.Result, '{', &t); err != nil {
+			errs[txid] = fmt.Errorf("error unmarshalling response: %w", err)
+			continue
+		}
+		ret[txid] = &t
+	}
+	return ret, errs, nil
+}
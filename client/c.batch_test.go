@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// node answering the batch in reverse, odd requests fail
+func TestBatch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var reqs []RPCRequest
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			t.Errorf("not a batch: %s", body)
+			return
+		}
+		resps := make([]RPCResponse, 0, len(reqs))
+		for i := len(reqs) - 1; i >= 0; i-- {
+			req := reqs[i]
+			if req.Id%2 == 1 {
+				resps = append(resps, RPCResponse{Id: req.Id, Error: &RPCError{Code: RPCInvalidAddressOrKey, Message: "No such mempool or blockchain transaction"}})
+				continue
+			}
+			params, _ := req.Params.([]interface{})
+			result, _ := json.Marshal(params[0])
+			resps = append(resps, RPCResponse{Id: req.Id, Result: result})
+		}
+		// unknown id is dropped
+		resps = append(resps, RPCResponse{Id: len(reqs), Result: json.RawMessage(`"extra"`)})
+		_ = json.NewEncoder(w).Encode(resps)
+	}))
+	t.Cleanup(s.Close)
+	c, _ := NewClient(s.URL, "user", "pass")
+
+	txids := []string{"a", "b", "c", "d", "e"}
+	reqs := make([]*RPCRequest, 0, len(txids))
+	for _, txid := range txids {
+		reqs = append(reqs, NewRPCRequest("getrawtransaction", []interface{}{txid, 1}))
+	}
+	resps, err := c.Batch(context.Background(), reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resps) != len(txids) {
+		t.Fatalf("%d responses, want %d", len(resps), len(txids))
+	}
+	for i, resp := range resps {
+		if resp == nil || resp.Id != i {
+			t.Fatalf("%d: response %+v", i, resp)
+		}
+		if i%2 == 1 {
+			if !IsNotFound(resp.Error) {
+				t.Fatalf("%d: error %v, want not found", i, resp.Error)
+			}
+			continue
+		}
+		var got string
+		if resp.Error != nil || json.Unmarshal(resp.Result, &got) != nil || got != txids[i] {
+			t.Fatalf("%d: result %s, error %v, want %q", i, resp.Result, resp.Error, txids[i])
+		}
+	}
+}
+
+// whole batch rejected by the node
+func TestBatchError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"result":null,"error":{"code":-32700,"message":"Parse error"},"id":null}`))
+	}))
+	t.Cleanup(s.Close)
+	c, _ := NewClient(s.URL, "user", "pass")
+	c.retries = 0
+	resps, err := c.Batch(context.Background(), []*RPCRequest{NewRPCRequest("getblockcount", []interface{}{})})
+	if err == nil {
+		t.Fatalf("responses %+v, want an error", resps)
+	}
+}
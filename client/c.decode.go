@@ -0,0 +1,67 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// results are kept as raw json and decoded once into the typed structs.
+// no interface{} round trip, getrawmempool of 80k txs was decoded 3 times
+
+// first non space byte of the json value
+func jsonKind(raw []byte) byte {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	if len(raw) == 0 {
+		return 0
+	}
+	return raw[0]
+}
+
+// kind of the first array item, 0 if the array is empty
+func jsonArrayKind(raw []byte) byte {
+	raw = bytes.TrimLeft(raw, " \t\r\n")
+	if len(raw) == 0 || raw[0] != '[' {
+		return 0
+	}
+	k := jsonKind(raw[1:])
+	if k == ']' {
+		return 0
+	}
+	return k
+}
+
+// decode the result checking the json type first, e.g. '{' for the object
+func decodeResult(raw json.RawMessage, kind byte, ret interface{}) error {
+	if jsonKind(raw) != kind {
+		return fmt.Errorf("unexpected type for result")
+	}
+	return json.Unmarshal(raw, ret)
+}
+
+// decode the object entry by entry without building the map
+func decodeObject(raw json.RawMessage, fn func(key string, dec *json.Decoder) error) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("unexpected type for result")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("unexpected key %v", t)
+		}
+		if err := fn(key, dec); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
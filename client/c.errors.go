@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// node RPC error codes
+// https://github.com/bitcoin/bitcoin/blob/master/src/rpc/protocol.h
+const (
+	RPCMethodNotFound        = -32601
+	RPCInvalidAddressOrKey   = -5 // tx or block not found
+	RPCInWarmup              = -28
+	RPCClientNotConnected    = -9
+	RPCClientInInitialDownld = -10
+)
+
+var (
+	// node is down, overloaded or not ready, can be retried later or on the other node
+	ErrUnavailable = errors.New("node unavailable")
+	// tx or block is not known to the node
+	ErrNotFound = errors.New("not found")
+)
+
+// error object of the JSON-RPC response
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// errors.Is(err, ErrNotFound) and errors.Is(err, ErrUnavailable)
+func (e *RPCError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == RPCInvalidAddressOrKey
+	case ErrUnavailable:
+		return e.Code == RPCInWarmup || e.Code == RPCClientNotConnected || e.Code == RPCClientInInitialDownld
+	}
+	return false
+}
+
+// btcd returns the error as a string sometimes
+func (e *RPCError) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		e.Message = s
+		return nil
+	}
+	type rpcError RPCError
+	return json.Unmarshal(data, (*rpcError)(e))
+}
+
+// non JSON-RPC response: 5xx, "work queue depth exceeded" etc
+type HTTPError struct {
+	Status int
+	Body   string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http error %d: %s", e.Status, e.Body)
+}
+
+func (e *HTTPError) Is(target error) bool {
+	return target == ErrUnavailable && (e.Status >= 500 || e.Status == 429)
+}
+
+// connection refused, timeout etc
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("transport error: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TransportError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
+
+// exponential backoff with jitter
+var (
+	backoffBase = 100 * time.Millisecond
+	backoffMax  = 5 * time.Second
+)
+
+// delay before the retry, random in [d/2, d) to not overload the node with synced retries
+func backoff(attempt int) time.Duration {
+	d := backoffBase << uint(attempt)
+	if d <= 0 || d > backoffMax {
+		d = backoffMax
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,351 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/1F47E/go-feesh/entity/btc/block"
+	"github.com/1F47E/go-feesh/entity/btc/info"
+	"github.com/1F47E/go-feesh/entity/btc/peer"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+)
+
+// FakeNode is an in-memory node for the deterministic core tests.
+// chain, pool and txs are set up by the test, scripted steps are applied with Next.
+// errors can be injected per RPC method name with Fail
+type FakeNode struct {
+	mu     *sync.Mutex
+	chain  []*block.Block // by height, tip is the last
+	blocks map[string]*block.Block
+	txs    map[string]*tx.Transaction
+	pool   []txpool.TxPool // old first, returned new first same as the patched getrawmempool
+	peers  []*peer.Peer
+	fails  map[string][]error
+	calls  map[string]int
+	steps  []func(f *FakeNode)
+	mined  int // nonce for the block hashes, blocks of the reorg differ
+}
+
+func NewFakeNode() *FakeNode {
+	f := &FakeNode{
+		mu:     &sync.Mutex{},
+		blocks: make(map[string]*block.Block),
+		txs:    make(map[string]*tx.Transaction),
+		fails:  make(map[string][]error),
+		calls:  make(map[string]int),
+	}
+	// genesis
+	f.mine(nil)
+	return f
+}
+
+// ===== setup
+
+// known tx, e.g. the prevout of the pool tx
+func (f *FakeNode) AddTx(t *tx.Transaction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.txs[t.Txid] = t
+}
+
+// tx in the pool, entry txid is set from the tx
+func (f *FakeNode) AddPoolTx(t *tx.Transaction, e txpool.TxPool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e.Txid = t.Txid
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+	f.txs[t.Txid] = t
+	f.pool = append(f.pool, e)
+}
+
+// tx left the pool without being mined, e.g. replaced or expired
+func (f *FakeNode) RemovePoolTx(txid string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removePool(map[string]bool{txid: true})
+}
+
+// mine a block on the tip with the txs, they are removed from the pool.
+// coinbase is added as the first tx
+func (f *FakeNode) Mine(txids ...string) *block.Block {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.mine(txids)
+}
+
+// drop the last blocks, the txs are not returned to the pool
+func (f *FakeNode) Reorg(depth int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 0; i < depth && len(f.chain) > 1; i++ {
+		last := f.chain[len(f.chain)-1]
+		f.chain = f.chain[:len(f.chain)-1]
+		delete(f.blocks, last.Hash)
+	}
+}
+
+func (f *FakeNode) SetPeers(peers []*peer.Peer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.peers = peers
+}
+
+// next calls of the method return the errors in order, e.g. Fail("getinfo", ErrUnavailable)
+func (f *FakeNode) Fail(method string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fails[method] = append(f.fails[method], errs...)
+}
+
+// number of calls of the method
+func (f *FakeNode) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// steps applied one by one with Next
+func (f *FakeNode) Script(steps ...func(f *FakeNode)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.steps = append(f.steps, steps...)
+}
+
+// apply the next scripted step, false if there are no more
+func (f *FakeNode) Next() bool {
+	f.mu.Lock()
+	if len(f.steps) == 0 {
+		f.mu.Unlock()
+		return false
+	}
+	step := f.steps[0]
+	f.steps = f.steps[1:]
+	f.mu.Unlock()
+	step(f)
+	return true
+}
+
+func (f *FakeNode) mine(txids []string) *block.Block {
+	height := len(f.chain)
+	f.mined++
+	sum := sha256.Sum256([]byte(strconv.Itoa(height) + ":" + strconv.Itoa(f.mined)))
+	coinbase := &tx.Transaction{
+		Txid: fmt.Sprintf("%064x", f.mined),
+		Vin:  []tx.Vin{{Coinbase: "fake"}},
+		Vout: []tx.Vout{{Value: 6.25, N: 0}},
+	}
+	f.txs[coinbase.Txid] = coinbase
+	b := &block.Block{
+		Hash:         hex.EncodeToString(sum[:]),
+		Height:       height,
+		Time:         int(time.Now().Unix()),
+		Transactions: append([]string{coinbase.Txid}, txids...),
+	}
+	if height > 0 {
+		b.Previousblockhash = f.chain[height-1].Hash
+	}
+	f.chain = append(f.chain, b)
+	f.blocks[b.Hash] = b
+	mined := make(map[string]bool, len(txids))
+	for _, txid := range txids {
+		mined[txid] = true
+	}
+	f.removePool(mined)
+	return b
+}
+
+func (f *FakeNode) removePool(txids map[string]bool) {
+	pool := f.pool[:0]
+	for _, e := range f.pool {
+		if !txids[e.Txid] {
+			pool = append(pool, e)
+		}
+	}
+	f.pool = pool
+}
+
+// count the call, pop the injected error if any
+func (f *FakeNode) call(ctx context.Context, method string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.calls[method]++
+	if errs := f.fails[method]; len(errs) > 0 {
+		f.fails[method] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+func notFound(what string) error {
+	return &RPCError{Code: RPCInvalidAddressOrKey, Message: what + " not found"}
+}
+
+func (f *FakeNode) tip() *block.Block {
+	return f.chain[len(f.chain)-1]
+}
+
+// copy with the confirmations set, header has no txs
+func (f *FakeNode) blockCopy(b *block.Block, header bool) *block.Block {
+	ret := *b
+	ret.Confirmations = f.tip().Height - b.Height + 1
+	if header {
+		ret.Transactions = nil
+	} else {
+		ret.Transactions = append([]string{}, b.Transactions...)
+	}
+	return &ret
+}
+
+// ===== Node
+
+func (f *FakeNode) GetInfo(ctx context.Context) (*info.Info, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getinfo"); err != nil {
+		return nil, err
+	}
+	return &info.Info{Blocks: f.tip().Height, Connections: len(f.peers), Testnet: true}, nil
+}
+
+func (f *FakeNode) GetBestBlock(ctx context.Context) (*ResponseGetBestBlock, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getbestblock"); err != nil {
+		return nil, err
+	}
+	return &ResponseGetBestBlock{Hash: f.tip().Hash, Height: f.tip().Height}, nil
+}
+
+func (f *FakeNode) GetBlockHash(ctx context.Context, height int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getblockhash"); err != nil {
+		return "", err
+	}
+	if height < 0 || height >= len(f.chain) {
+		return "", &RPCError{Code: -8, Message: "Block height out of range"}
+	}
+	return f.chain[height].Hash, nil
+}
+
+func (f *FakeNode) GetBlockHeader(ctx context.Context, hash string) (*block.Block, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getblockheader"); err != nil {
+		return nil, err
+	}
+	b, ok := f.blocks[hash]
+	if !ok {
+		return nil, notFound("block " + hash)
+	}
+	return f.blockCopy(b, true), nil
+}
+
+func (f *FakeNode) GetBlock(ctx context.Context, hash string) (*block.Block, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getblock"); err != nil {
+		return nil, err
+	}
+	b, ok := f.blocks[hash]
+	if !ok {
+		return nil, notFound("block " + hash)
+	}
+	return f.blockCopy(b, false), nil
+}
+
+func (f *FakeNode) TransactionGet(ctx context.Context, txid string) (*tx.Transaction, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getrawtransaction"); err != nil {
+		return nil, fmt.Errorf("error on getrawtransaction: %w", err)
+	}
+	t, ok := f.txs[txid]
+	if !ok {
+		return nil, fmt.Errorf("error on getrawtransaction: %w", notFound("tx "+txid))
+	}
+	ret := *t
+	return &ret, nil
+}
+
+func (f *FakeNode) TransactionGetMany(ctx context.Context, txids []string) (map[string]*tx.Transaction, map[string]error, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getrawtransaction"); err != nil {
+		return nil, nil, fmt.Errorf("error on getrawtransaction batch: %w", err)
+	}
+	ret := make(map[string]*tx.Transaction, len(txids))
+	errs := make(map[string]error)
+	for _, txid := range txids {
+		t, ok := f.txs[txid]
+		if !ok {
+			errs[txid] = notFound("tx " + txid)
+			continue
+		}
+		c := *t
+		ret[txid] = &c
+	}
+	return ret, errs, nil
+}
+
+func (f *FakeNode) RawMempool(ctx context.Context) ([]txpool.TxPool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getrawmempool"); err != nil {
+		return nil, err
+	}
+	ret := make([]txpool.TxPool, len(f.pool))
+	for i, e := range f.pool {
+		ret[len(f.pool)-1-i] = e
+	}
+	return ret, nil
+}
+
+func (f *FakeNode) MempoolEntry(ctx context.Context, txid string) (*txpool.TxPool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getmempoolentry"); err != nil {
+		return nil, err
+	}
+	for _, e := range f.pool {
+		if e.Txid == txid {
+			ret := e
+			return &ret, nil
+		}
+	}
+	return nil, notFound("tx " + txid)
+}
+
+func (f *FakeNode) GetPeers(ctx context.Context) ([]*peer.Peer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "getpeerinfo"); err != nil {
+		return nil, err
+	}
+	return append([]*peer.Peer{}, f.peers...), nil
+}
+
+func (f *FakeNode) HealthCheck(ctx context.Context) {}
+
+func (f *FakeNode) Backends() []BackendStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return []BackendStatus{{
+		Host:        "fake",
+		Active:      true,
+		Reachable:   true,
+		Synced:      true,
+		Height:      f.tip().Height,
+		MempoolSize: len(f.pool),
+		LastCheck:   time.Now(),
+	}}
+}
@@ -1,8 +1,8 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 
 	"github.com/1F47E/go-feesh/entity/btc/txpool"
 	log "github.com/1F47E/go-feesh/logger"
@@ -12,6 +12,7 @@ import (
 // curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getrawmempool","params":[],"id":1}' http://localhost:18334
 // NOTE: In order to have close to realtime mempool info bitcoin node should be patched.
 // By default getrawmempool by default returns unsorted list of transactions.
+// On the stock node the entries are fetched with getrawmempool true / getmempoolentry, see c.stock.go
 
 // custom response format with additional data
 /*
@@ -23,27 +24,25 @@ import (
     "fee_kb": 3647
   }
 */
-func (c *Client) RawMempool() ([]txpool.TxPool, error) {
+func (c *Client) RawMempool(ctx context.Context) ([]txpool.TxPool, error) {
 	r := NewRPCRequest("getrawmempool", []interface{}{})
-	data, err := c.doRequest(r)
+	data, err := c.doRequest(ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	// check type of result
-	if _, ok := data.Result.([]interface{}); !ok {
-		log.Log.Debugf("rawmempool result type: %T\n", data.Result)
-		log.Log.Debugf("rawmempool result: %+v\n", data.Result)
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
-	if err != nil {
-		return nil, err
+	// stock node returns just the txids
+	if jsonArrayKind(data.Result) == '"' {
+		var txids []string
+		if err := json.Unmarshal(data.Result, &txids); err != nil {
+			return nil, err
+		}
+		return c.rawMempoolStock(ctx, txids)
 	}
 
 	var ret []txpool.TxPool
-	err = json.Unmarshal(rawJson, &ret)
+	err = decodeResult(data.Result, '[', &ret)
 	if err != nil {
+		log.Log.Debugf("rawmempool result: %.200s\n", data.Result)
 		return nil, err
 	}
 	return ret, nil
@@ -52,35 +51,39 @@ func (c *Client) RawMempool() ([]txpool.TxPool, error) {
 // rawmempool request extended
 // curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getrawmempool","params":[true],"id":1}' http://localhost:18334
 // NOTE: takes a long time. 1+ min for the pool of 80k txs
-func (c *Client) RawMempoolVerbose() ([]txpool.TxPoolVerbose, error) {
+func (c *Client) RawMempoolVerbose(ctx context.Context) ([]txpool.TxPoolVerbose, error) {
 	// extended
 	r := NewRPCRequest("getrawmempool", []interface{}{true})
-	data, err := c.doRequest(r)
+	data, err := c.doRequest(ctx, r)
 	if err != nil {
 		return nil, err
 	}
-	// check type of result
-	if _, ok := data.Result.(map[string]interface{}); !ok {
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
+	res := make([]txpool.TxPoolVerbose, 0)
+	// entries are decoded one by one, no map of the whole pool
+	err = decodeObject(data.Result, func(txid string, dec *json.Decoder) error {
+		var v txpool.TxPoolVerbose
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		v.Txid = txid
+		v.Hash = txid
+		res = append(res, v)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	log.Log.Debugf("raw mempool transactions found %d\n", len(res))
+	return res, nil
+}
 
-	var resp map[string]txpool.TxPoolVerbose
-	err = json.Unmarshal(rawJson, &resp)
-	if err != nil {
+// single pool entry in the same format as the patched getrawmempool
+// curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getmempoolentry","params":["0798ca60f8e42bc8ca4bf38b3449f05f6605136a30991e3962657b33fd2b035f"],"id":1}' http://localhost:18334
+func (c *Client) MempoolEntry(ctx context.Context, txid string) (*txpool.TxPool, error) {
+	var v txpool.TxPoolVerbose
+	if err := c.call(ctx, "getmempoolentry", []interface{}{txid}, &v); err != nil {
 		return nil, err
 	}
-
-	res := make([]txpool.TxPoolVerbose, 0)
-	log.Log.Debugf("raw mempool transactions found %d\n", len(resp))
-	for k, v := range resp {
-		v.Hash = k
-		// log.Printf("txid: %s, fee: %f\n", k, v.Fee)
-		res = append(res, v)
-	}
-	return res, nil
+	ret := v.ToTxPool(txid)
+	return &ret, nil
 }
@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+)
+
+// getrawmempool benchmarks over the recorded responses, size of the big mainnet pool.
+// set BENCH_RECORDING to the RPC_RECORD_FILE of the live node to use the real one
+
+const benchPoolSize = 80_000
+
+func benchTxid(i int) string {
+	return fmt.Sprintf("%064x", i)
+}
+
+func benchRecord(b *testing.B, name string, pairs ...interface{}) string {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), name+".gz")
+	r, err := NewRecorder(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		req, _ := json.Marshal(pairs[i])
+		resp, _ := json.Marshal(RPCResponse{Jsonrpc: "1.0", Result: mustJson(b, pairs[i+1]), Id: 1})
+		r.Record(time.Now(), "bench", req, resp)
+	}
+	if err := r.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func mustJson(b *testing.B, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+// patched node response, new first
+func benchPool(n int) []txpool.TxPool {
+	now := time.Now().Unix()
+	ret := make([]txpool.TxPool, n)
+	for i := range ret {
+		ret[i] = txpool.TxPool{
+			Txid:     benchTxid(i),
+			Time:     now - int64(i),
+			Size:     uint32(150 + i%500),
+			Vsize:    uint32(140 + i%500),
+			Weight:   uint32(560 + i%2000),
+			Fee:      uint64(1000 + i%50_000),
+			FeePerKB: uint64(7000 + i%100_000),
+		}
+	}
+	return ret
+}
+
+// getrawmempool true, bitcoin core format
+func benchPoolVerbose(n int) map[string]interface{} {
+	now := time.Now().Unix()
+	ret := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		depends := []string{}
+		if i%10 == 0 && i > 0 {
+			depends = append(depends, benchTxid(i-1))
+		}
+		ret[benchTxid(i)] = map[string]interface{}{
+			"vsize":   140 + i%500,
+			"weight":  560 + i%2000,
+			"time":    now - int64(i),
+			"height":  800_000,
+			"fees":    map[string]float64{"base": 0.00001, "modified": 0.00001, "ancestor": 0.00001, "descendant": 0.00001},
+			"depends": depends,
+		}
+	}
+	return ret
+}
+
+func benchClient(b *testing.B, path string) *Client {
+	b.Helper()
+	c, err := NewClient("http://replay", "user", "pass")
+	if err != nil {
+		b.Fatal(err)
+	}
+	r, err := NewReplayer(path, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c.SetReplayer(r)
+	return c
+}
+
+func BenchmarkRawMempool(b *testing.B) {
+	path := os.Getenv("BENCH_RECORDING")
+	if path == "" {
+		path = benchRecord(b, "patched",
+			NewRPCRequest("getrawmempool", []interface{}{}), benchPool(benchPoolSize))
+	}
+	c := benchClient(b, path)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool, err := c.RawMempool(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(pool) == 0 {
+			b.Fatal("empty pool")
+		}
+	}
+}
+
+func BenchmarkRawMempoolVerbose(b *testing.B) {
+	path := benchRecord(b, "verbose",
+		NewRPCRequest("getrawmempool", []interface{}{true}), benchPoolVerbose(benchPoolSize))
+	c := benchClient(b, path)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool, err := c.RawMempoolVerbose(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(pool) != benchPoolSize {
+			b.Fatalf("pool size %d", len(pool))
+		}
+	}
+}
+
+// stock node, txids only, entries are cached after the first call
+func BenchmarkRawMempoolStock(b *testing.B) {
+	txids := make([]string, benchPoolSize)
+	for i := range txids {
+		txids[i] = benchTxid(i)
+	}
+	path := benchRecord(b, "stock",
+		NewRPCRequest("getrawmempool", []interface{}{}), txids,
+		NewRPCRequest("getrawmempool", []interface{}{true}), benchPoolVerbose(benchPoolSize))
+	c := benchClient(b, path)
+	ctx := context.Background()
+	if _, err := c.RawMempool(ctx); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool, err := c.RawMempool(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(pool) != benchPoolSize {
+			b.Fatalf("pool size %d", len(pool))
+		}
+	}
+}
+
+// decoding only, the response body as read from the node
+func benchBody(b *testing.B) []byte {
+	b.Helper()
+	body, err := json.Marshal(RPCResponse{Jsonrpc: "1.0", Result: mustJson(b, benchPool(benchPoolSize)), Id: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return body
+}
+
+func BenchmarkRawMempoolDecode(b *testing.B) {
+	body := benchBody(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := decodeResponse(body)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var ret []txpool.TxPool
+		if err := decodeResult(data.Result, '[', &ret); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// the way it was decoded before: result into interface{}, marshal and unmarshal again
+func BenchmarkRawMempoolDecodeInterface(b *testing.B) {
+	body := benchBody(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var data struct {
+			Result interface{} `json:"result"`
+		}
+		if err := json.Unmarshal(body, &data); err != nil {
+			b.Fatal(err)
+		}
+		raw, err := json.Marshal(data.Result)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var ret []txpool.TxPool
+		if err := json.Unmarshal(raw, &ret); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
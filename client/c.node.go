@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+
+	"github.com/1F47E/go-feesh/entity/btc/block"
+	"github.com/1F47E/go-feesh/entity/btc/info"
+	"github.com/1F47E/go-feesh/entity/btc/peer"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+)
+
+// node access used by the core.
+// implemented by the RPC Client and the in-memory FakeNode
+type Node interface {
+	GetInfo(ctx context.Context) (*info.Info, error)
+	GetBestBlock(ctx context.Context) (*ResponseGetBestBlock, error)
+	GetBlockHash(ctx context.Context, height int) (string, error)
+	GetBlockHeader(ctx context.Context, hash string) (*block.Block, error)
+	GetBlock(ctx context.Context, hash string) (*block.Block, error)
+	TransactionGet(ctx context.Context, txid string) (*tx.Transaction, error)
+	TransactionGetMany(ctx context.Context, txids []string) (map[string]*tx.Transaction, map[string]error, error)
+	RawMempool(ctx context.Context) ([]txpool.TxPool, error)
+	MempoolEntry(ctx context.Context, txid string) (*txpool.TxPool, error)
+	GetPeers(ctx context.Context) ([]*peer.Peer, error)
+	// failover, single node implementations have nothing to check
+	HealthCheck(ctx context.Context)
+	Backends() []BackendStatus
+}
+
+var _ Node = (*Client)(nil)
+var _ Node = (*FakeNode)(nil)
@@ -0,0 +1,319 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/1F47E/go-feesh/logger"
+)
+
+// RPC sessions recording and replay.
+// every request/response pair is written as a json line to the gzip file,
+// batches are split into the single pairs so they can be served in any composition
+
+type record struct {
+	Time     time.Time       `json:"time"`
+	TookMs   int64           `json:"took_ms"`
+	Backend  string          `json:"backend"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+type Recorder struct {
+	mu  *sync.Mutex
+	f   *os.File
+	gz  *gzip.Writer
+	enc *json.Encoder
+	err error
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	return &Recorder{
+		mu:  &sync.Mutex{},
+		f:   f,
+		gz:  gz,
+		enc: json.NewEncoder(gz),
+	}, nil
+}
+
+// record everything the client sends
+func (c *Client) SetRecorder(r *Recorder) {
+	c.recorder = r
+}
+
+func (r *Recorder) Record(start time.Time, backend string, req, resp []byte) {
+	took := time.Since(start).Milliseconds()
+	reqs, resps, ok := splitBatch(req, resp)
+	if !ok {
+		reqs, resps = []json.RawMessage{req}, []json.RawMessage{resp}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	for i := range reqs {
+		rec := record{Time: start, TookMs: took, Backend: backend, Request: reqs[i], Response: resps[i]}
+		if err := r.enc.Encode(rec); err != nil {
+			r.fail(err)
+			return
+		}
+	}
+	// flushed every time, the app is killed without closing the file
+	if err := r.gz.Flush(); err != nil {
+		r.fail(err)
+	}
+}
+
+func (r *Recorder) fail(err error) {
+	r.err = err
+	log.Log.WithField("context", "[record]").Errorf("recording stopped: %v\n", err)
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.gz.Close(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// pairs of the batch matched by id, false if its not a batch
+func splitBatch(req, resp []byte) ([]json.RawMessage, []json.RawMessage, bool) {
+	if jsonKind(req) != '[' || jsonKind(resp) != '[' {
+		return nil, nil, false
+	}
+	var reqs, resps []json.RawMessage
+	if json.Unmarshal(req, &reqs) != nil || json.Unmarshal(resp, &resps) != nil {
+		return nil, nil, false
+	}
+	byId := make(map[int]json.RawMessage, len(resps))
+	for _, r := range resps {
+		var v struct {
+			Id int `json:"id"`
+		}
+		if json.Unmarshal(r, &v) == nil {
+			byId[v.Id] = r
+		}
+	}
+	retReqs := make([]json.RawMessage, 0, len(reqs))
+	retResps := make([]json.RawMessage, 0, len(reqs))
+	for _, r := range reqs {
+		var v struct {
+			Id int `json:"id"`
+		}
+		if json.Unmarshal(r, &v) != nil {
+			continue
+		}
+		if resp, ok := byId[v.Id]; ok {
+			retReqs = append(retReqs, r)
+			retResps = append(retResps, resp)
+		}
+	}
+	return retReqs, retResps, true
+}
+
+// ===== replay
+
+// responses of the same request over time
+type replayed struct {
+	times []time.Duration // since the recording start
+	resps []json.RawMessage
+}
+
+// Replayer serves the recorded responses instead of the node.
+// request gets the latest response recorded before the current replay time,
+// or the first one if it was not requested yet at that time, e.g. tx lookups
+type Replayer struct {
+	start  time.Time
+	speed  float64
+	length time.Duration
+	items  map[string]*replayed
+	begin  *sync.Once
+	end    *sync.Once
+}
+
+// speed 1 is the original timeline, 10 is 10 times faster
+func NewReplayer(path string, speed float64) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+	r := &Replayer{speed: speed, items: make(map[string]*replayed), begin: &sync.Once{}, end: &sync.Once{}}
+
+	var first time.Time
+	sc := bufio.NewScanner(gz)
+	// getrawmempool of the big pool is a single line
+	sc.Buffer(make([]byte, 0, 1<<20), 1<<30)
+	cnt := 0
+	for sc.Scan() {
+		var rec record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("record %d: %w", cnt+1, err)
+		}
+		if first.IsZero() {
+			first = rec.Time
+		}
+		key, err := replayKey(rec.Request)
+		if err != nil {
+			continue
+		}
+		item, ok := r.items[key]
+		if !ok {
+			item = &replayed{}
+			r.items[key] = item
+		}
+		at := rec.Time.Sub(first)
+		item.times = append(item.times, at)
+		item.resps = append(item.resps, rec.Response)
+		if at > r.length {
+			r.length = at
+		}
+		cnt++
+	}
+	// not closed recording is cut in the middle of the gzip stream
+	if err := sc.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if cnt == 0 {
+		return nil, fmt.Errorf("no records in %s", path)
+	}
+	// concurrent requests can be recorded out of order
+	for _, item := range r.items {
+		sort.Stable(item)
+	}
+	log.Log.WithField("context", "[replay]").Infof("loaded %d records, %d requests, %v long\n", cnt, len(r.items), r.length)
+	return r, nil
+}
+
+func (p *replayed) Len() int           { return len(p.times) }
+func (p *replayed) Less(i, j int) bool { return p.times[i] < p.times[j] }
+func (p *replayed) Swap(i, j int) {
+	p.times[i], p.times[j] = p.times[j], p.times[i]
+	p.resps[i], p.resps[j] = p.resps[j], p.resps[i]
+}
+
+// serve the node from the recording, the timeline starts with the first request
+func (c *Client) SetReplayer(r *Replayer) {
+	c.client.Transport = r
+}
+
+// method and params, id is ignored
+func replayKey(req []byte) (string, error) {
+	var v struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(req, &v); err != nil {
+		return "", err
+	}
+	var params bytes.Buffer
+	if len(v.Params) > 0 {
+		if err := json.Compact(&params, v.Params); err != nil {
+			return "", err
+		}
+	}
+	return v.Method + params.String(), nil
+}
+
+// position in the recording
+func (r *Replayer) now() time.Duration {
+	r.begin.Do(func() {
+		r.start = time.Now()
+	})
+	at := time.Duration(float64(time.Since(r.start)) * r.speed)
+	if at > r.length {
+		// the last responses are served from now on
+		r.end.Do(func() {
+			log.Log.WithField("context", "[replay]").Warn("recording is over")
+		})
+	}
+	return at
+}
+
+func (r *Replayer) response(req []byte, at time.Duration) json.RawMessage {
+	var v struct {
+		Id int `json:"id"`
+	}
+	_ = json.Unmarshal(req, &v)
+	var resp json.RawMessage
+	key, err := replayKey(req)
+	if item, ok := r.items[key]; err == nil && ok {
+		i := sort.Search(len(item.times), func(i int) bool { return item.times[i] > at })
+		if i > 0 {
+			i--
+		}
+		resp = item.resps[i]
+	}
+	if resp == nil {
+		resp, _ = json.Marshal(RPCResponse{Jsonrpc: "1.0", Error: &RPCError{Code: -1, Message: "not in the recording"}})
+	}
+	// id of the request, batch responses are matched by id
+	var ret map[string]json.RawMessage
+	if json.Unmarshal(resp, &ret) != nil {
+		return resp
+	}
+	ret["id"], _ = json.Marshal(v.Id)
+	out, _ := json.Marshal(ret)
+	return out
+}
+
+// http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	at := r.now()
+	var out []byte
+	if jsonKind(body) == '[' {
+		var reqs []json.RawMessage
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			return nil, err
+		}
+		resps := make([]json.RawMessage, 0, len(reqs))
+		for _, one := range reqs {
+			resps = append(resps, r.response(one, at))
+		}
+		out, _ = json.Marshal(resps)
+	} else {
+		out = r.response(body, at)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(out)),
+		ContentLength: int64(len(out)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// node answering getblockhash with the hash of the current version.
+// batch responses come in the reverse order, they are matched by id
+func recordNode(t *testing.T, version *int32) *httptest.Server {
+	t.Helper()
+	answer := func(req json.RawMessage) RPCResponse {
+		var r struct {
+			Method string `json:"method"`
+			Params []int  `json:"params"`
+			Id     int    `json:"id"`
+		}
+		_ = json.Unmarshal(req, &r)
+		if r.Method != "getblockhash" || len(r.Params) != 1 {
+			return RPCResponse{Id: r.Id, Error: &RPCError{Code: -32601, Message: "method not found"}}
+		}
+		hash, _ := json.Marshal(fmt.Sprintf("h%d-v%d", r.Params[0], atomic.LoadInt32(version)))
+		return RPCResponse{Jsonrpc: "1.0", Id: r.Id, Result: hash}
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var out []byte
+		if jsonKind(body) == '[' {
+			var reqs []json.RawMessage
+			_ = json.Unmarshal(body, &reqs)
+			resps := make([]RPCResponse, 0, len(reqs))
+			for i := len(reqs) - 1; i >= 0; i-- {
+				resps = append(resps, answer(reqs[i]))
+			}
+			out, _ = json.Marshal(resps)
+		} else {
+			out, _ = json.Marshal(answer(body))
+		}
+		_, _ = w.Write(out)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func blockHashes(t *testing.T, c *Client, heights ...int) []string {
+	t.Helper()
+	reqs := make([]*RPCRequest, len(heights))
+	for i, h := range heights {
+		reqs[i] = NewRPCRequest("getblockhash", []interface{}{h})
+	}
+	resps, err := c.Batch(context.Background(), reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := make([]string, len(resps))
+	for i, resp := range resps {
+		if resp == nil {
+			t.Fatalf("no response for %d", heights[i])
+		}
+		if resp.Error != nil {
+			ret[i] = resp.Error.Message
+			continue
+		}
+		_ = json.Unmarshal(resp.Result, &ret[i])
+	}
+	return ret
+}
+
+func checkHash(t *testing.T, c *Client, height int, want string) {
+	t.Helper()
+	hash, err := c.GetBlockHash(context.Background(), height)
+	if err != nil || hash != want {
+		t.Fatalf("block %d hash %q, %v, want %q", height, hash, err, want)
+	}
+}
+
+func checkHashes(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("hashes %v, want %v", got, want)
+	}
+}
+
+// recorded session replayed 5 times faster gets the same responses on the same timeline
+func TestRecordReplay(t *testing.T) {
+	const pause = 500 * time.Millisecond
+	var version int32 = 1
+	node := recordNode(t, &version)
+	path := filepath.Join(t.TempDir(), "session.jsonl.gz")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := NewClient(node.URL, "user", "pass")
+	c.SetRecorder(rec)
+
+	checkHash(t, c, 1, "h1-v1")
+	checkHashes(t, blockHashes(t, c, 1, 2, 3), "h1-v1", "h2-v1", "h3-v1")
+	time.Sleep(pause)
+	atomic.StoreInt32(&version, 2)
+	checkHash(t, c, 1, "h1-v2")
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplayer(path, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ = NewClient("http://replay", "user", "pass")
+	c.SetReplayer(replay)
+
+	checkHash(t, c, 1, "h1-v1")
+	// recorded in the batch only
+	checkHash(t, c, 2, "h2-v1")
+	// other composition and order, responses are in the order of the requests
+	checkHashes(t, blockHashes(t, c, 3, 1, 4, 2), "h3-v1", "h1-v1", "not in the recording", "h2-v1")
+
+	time.Sleep(pause/5 + 50*time.Millisecond)
+	checkHash(t, c, 1, "h1-v2")
+	checkHashes(t, blockHashes(t, c, 2, 1), "h2-v1", "h1-v2")
+}
+
+// replayer answers the batch in the order of the requests, with their ids
+func TestReplayBatchOrder(t *testing.T) {
+	var version int32 = 1
+	node := recordNode(t, &version)
+	path := filepath.Join(t.TempDir(), "session.jsonl.gz")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := NewClient(node.URL, "user", "pass")
+	c.SetRecorder(rec)
+	blockHashes(t, c, 1, 2, 3)
+	rec.Close()
+
+	replay, err := NewReplayer(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `[{"jsonrpc":"1.0","method":"getblockhash","params":[3],"id":7},` +
+		`{"jsonrpc":"1.0","method":"getblockhash","params":[1],"id":5},` +
+		`{"jsonrpc":"1.0","method":"getblockhash","params":[2],"id":6}]`
+	req, _ := http.NewRequest(http.MethodPost, "http://replay", bytes.NewReader([]byte(body)))
+	resp, err := replay.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var resps []RPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&resps); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id   int
+		hash string
+	}{{7, `"h3-v1"`}, {5, `"h1-v1"`}, {6, `"h2-v1"`}}
+	if len(resps) != len(want) {
+		t.Fatalf("%d responses, want %d", len(resps), len(want))
+	}
+	for i, w := range want {
+		if resps[i].Id != w.id || string(resps[i].Result) != w.hash {
+			t.Fatalf("%d: id %d result %s, want id %d result %s", i, resps[i].Id, resps[i].Result, w.id, w.hash)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/1F47E/go-feesh/entity/btc/block"
+	"github.com/1F47E/go-feesh/entity/btc/info"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	log "github.com/1F47E/go-feesh/logger"
+)
+
+// stock bitcoin core and btcd support.
+// patched btcd returns sorted getrawmempool with fees, stock node returns just txids.
+// bitcoin core also has no getinfo and getbestblock
+
+// more missing entries than this are fetched with single getrawmempool true call
+const mempoolEntriesMax = 500
+
+type capabilities struct {
+	getInfo      bool
+	getBestBlock bool
+}
+
+// pool entries by txid, only new txs are requested from the node
+type mempoolCache struct {
+	mu      *sync.Mutex
+	entries map[string]txpool.TxPool
+}
+
+func newMempoolCache() *mempoolCache {
+	return &mempoolCache{
+		mu:      &sync.Mutex{},
+		entries: make(map[string]txpool.TxPool),
+	}
+}
+
+// check which RPC methods the nodes have, called once on start.
+// unreachable backup nodes are checked when they are reachable again
+func (c *Client) Detect(ctx context.Context) error {
+	l := log.Log.WithField("context", "[RPC]")
+	var lastErr error
+	detected := 0
+	for _, b := range c.backends.all() {
+		if _, err := c.detect(ctx, b); err != nil {
+			l.Errorf("backend %s: %v\n", b.name(), err)
+			lastErr = err
+			continue
+		}
+		detected++
+	}
+	if detected == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// capabilities of the backend, checked on the first use if it was unreachable on start
+func (c *Client) backendCaps(ctx context.Context, b *backend) (capabilities, error) {
+	if caps, ok := b.getCaps(); ok {
+		return caps, nil
+	}
+	return c.detect(ctx, b)
+}
+
+func (c *Client) detect(ctx context.Context, b *backend) (capabilities, error) {
+	caps := capabilities{}
+	var err error
+	caps.getInfo, err = c.hasMethod(ctx, b, "getinfo")
+	if err == nil {
+		caps.getBestBlock, err = c.hasMethod(ctx, b, "getbestblock")
+	}
+	if err != nil {
+		return caps, err
+	}
+	b.setCaps(caps)
+	log.Log.WithField("context", "[RPC]").Infof("node %s capabilities: getinfo %v, getbestblock %v\n", b.name(), caps.getInfo, caps.getBestBlock)
+	return caps, nil
+}
+
+func (c *Client) hasMethod(ctx context.Context, b *backend, method string) (bool, error) {
+	_, err := c.doRequestTo(ctx, b, NewRPCRequest(method, []interface{}{}))
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code != RPCMethodNotFound, nil
+	}
+	return err == nil, err
+}
+
+// do the request and parse the result into ret
+func (c *Client) call(ctx context.Context, method string, params []interface{}, ret interface{}) error {
+	data, err := c.doRequest(ctx, NewRPCRequest(method, params))
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return unmarshalResult(method, data, ret)
+}
+
+// pool entries for the txids from the stock getrawmempool, new first.
+// entries are requested without the cache lock, the pool can be pulled meanwhile
+func (c *Client) rawMempoolStock(ctx context.Context, items []string) ([]txpool.TxPool, error) {
+	c.mempool.mu.Lock()
+	txids := make(map[string]bool, len(items))
+	missing := make([]string, 0)
+	for _, txid := range items {
+		txids[txid] = true
+		if _, ok := c.mempool.entries[txid]; !ok {
+			missing = append(missing, txid)
+		}
+	}
+	for txid := range c.mempool.entries {
+		if !txids[txid] {
+			delete(c.mempool.entries, txid)
+		}
+	}
+	c.mempool.mu.Unlock()
+
+	fetched := make([]txpool.TxPool, 0, len(missing))
+	if len(missing) > mempoolEntriesMax {
+		verbose, err := c.RawMempoolVerbose(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range verbose {
+			fetched = append(fetched, v.ToTxPool(v.Txid))
+		}
+	} else {
+		var err error
+		fetched, err = c.mempoolEntries(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.mempool.mu.Lock()
+	defer c.mempool.mu.Unlock()
+	for _, e := range fetched {
+		c.mempool.entries[e.Txid] = e
+	}
+	ret := make([]txpool.TxPool, 0, len(txids))
+	for txid := range txids {
+		if e, ok := c.mempool.entries[txid]; ok {
+			ret = append(ret, e)
+		}
+	}
+	// same order as the patched node
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Time != ret[j].Time {
+			return ret[i].Time > ret[j].Time
+		}
+		return ret[i].Txid < ret[j].Txid
+	})
+	return ret, nil
+}
+
+// getmempoolentry in batches, the txs that already left the pool are skipped
+func (c *Client) mempoolEntries(ctx context.Context, txids []string) ([]txpool.TxPool, error) {
+	ret := make([]txpool.TxPool, 0, len(txids))
+	for len(txids) > 0 {
+		n := c.batchSize
+		if n > len(txids) {
+			n = len(txids)
+		}
+		reqs := make([]*RPCRequest, n)
+		for i, txid := range txids[:n] {
+			reqs[i] = NewRPCRequest("getmempoolentry", []interface{}{txid})
+		}
+		resps, err := c.Batch(ctx, reqs)
+		if err != nil {
+			return nil, fmt.Errorf("error on getmempoolentry batch: %w", err)
+		}
+		for i, resp := range resps {
+			txid := txids[i]
+			if resp == nil {
+				return nil, fmt.Errorf("getmempoolentry %s: no response in batch", txid)
+			}
+			if resp.Error != nil {
+				if IsNotFound(resp.Error) {
+					continue
+				}
+				return nil, fmt.Errorf("getmempoolentry %s: %w", txid, resp.Error)
+			}
+			var v txpool.TxPoolVerbose
+			if err := decodeResult(resp.Result, '{', &v); err != nil {
+				return nil, fmt.Errorf("getmempoolentry %s: %w", txid, err)
+			}
+			ret = append(ret, v.ToTxPool(txid))
+		}
+		txids = txids[n:]
+	}
+	return ret, nil
+}
+
+// getinfo from getblockchaininfo and getnetworkinfo
+func (c *Client) getInfoStock(ctx context.Context, b *backend) (*info.Info, error) {
+	var chain struct {
+		Chain      string  `json:"chain"`
+		Blocks     int     `json:"blocks"`
+		Difficulty float64 `json:"difficulty"`
+	}
+	if err := c.callTo(ctx, b, "getblockchaininfo", []interface{}{}, &chain); err != nil {
+		return nil, err
+	}
+	var network struct {
+		Version         int         `json:"version"`
+		ProtocolVersion int         `json:"protocolversion"`
+		Timeoffset      int         `json:"timeoffset"`
+		Connections     int         `json:"connections"`
+		Relayfee        float64     `json:"relayfee"`
+		Warnings        interface{} `json:"warnings"` // string or list in the newer versions
+	}
+	if err := c.callTo(ctx, b, "getnetworkinfo", []interface{}{}, &network); err != nil {
+		return nil, err
+	}
+	ret := &info.Info{
+		Version:         network.Version,
+		ProtocolVersion: network.ProtocolVersion,
+		Blocks:          chain.Blocks,
+		Timeoffset:      network.Timeoffset,
+		Connections:     network.Connections,
+		Difficulty:      chain.Difficulty,
+		Testnet:         chain.Chain == "test" || chain.Chain == "testnet4",
+		Relayfee:        network.Relayfee,
+	}
+	if w, ok := network.Warnings.(string); ok {
+		ret.Errors = w
+	}
+	return ret, nil
+}
+
+// getbestblock from getbestblockhash and the header
+func (c *Client) getBestBlockStock(ctx context.Context, b *backend) (*ResponseGetBestBlock, error) {
+	var hash string
+	if err := c.callTo(ctx, b, "getbestblockhash", []interface{}{}, &hash); err != nil {
+		return nil, err
+	}
+	var header block.Block
+	if err := c.callTo(ctx, b, "getblockheader", []interface{}{hash}, &header); err != nil {
+		return nil, err
+	}
+	return &ResponseGetBestBlock{Hash: hash, Height: header.Height}, nil
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stock node with the pool txids, getmempoolentry fails with the code for the txids in errs
+type stockPool struct {
+	*httptest.Server
+	mu      *sync.Mutex
+	txids   []string
+	errs    map[string]int
+	batches []int // sizes of the getmempoolentry batches
+}
+
+func newStockPool(t *testing.T, n int) *stockPool {
+	t.Helper()
+	p := &stockPool{mu: &sync.Mutex{}, errs: make(map[string]int)}
+	for i := 0; i < n; i++ {
+		p.txids = append(p.txids, fmt.Sprintf("%064x", i))
+	}
+	entry := func(req RPCRequest) RPCResponse {
+		params, _ := req.Params.([]interface{})
+		if req.Method != "getmempoolentry" || len(params) != 1 {
+			return RPCResponse{Id: req.Id, Error: &RPCError{Code: RPCMethodNotFound, Message: "Method not found"}}
+		}
+		txid, _ := params[0].(string)
+		if code, ok := p.errs[txid]; ok {
+			return RPCResponse{Id: req.Id, Error: &RPCError{Code: code, Message: "entry error"}}
+		}
+		var i int
+		fmt.Sscanf(txid, "%x", &i)
+		result, _ := json.Marshal(map[string]interface{}{"vsize": 200, "weight": 800, "time": 1000 + i, "fees": map[string]float64{"base": 0.00001}})
+		return RPCResponse{Id: req.Id, Result: result}
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		var out []byte
+		if jsonKind(body) == '[' {
+			var reqs []RPCRequest
+			_ = json.Unmarshal(body, &reqs)
+			p.batches = append(p.batches, len(reqs))
+			resps := make([]RPCResponse, len(reqs))
+			for i, req := range reqs {
+				resps[i] = entry(req)
+			}
+			out, _ = json.Marshal(resps)
+		} else {
+			var req RPCRequest
+			_ = json.Unmarshal(body, &req)
+			if req.Method != "getrawmempool" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			result, _ := json.Marshal(p.txids)
+			out, _ = json.Marshal(RPCResponse{Id: req.Id, Result: result})
+		}
+		_, _ = w.Write(out)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// entries of the new txs are batched, the ones that left the pool are skipped
+func TestRawMempoolStock(t *testing.T) {
+	p := newStockPool(t, 5)
+	p.errs[p.txids[2]] = RPCInvalidAddressOrKey
+	c, _ := NewClient(p.URL, "user", "pass")
+	c.SetBatchSize(2)
+
+	pool, err := c.RawMempool(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(p.batches) != "[2 2 1]" {
+		t.Fatalf("batches %v, want [2 2 1]", p.batches)
+	}
+	// new first
+	want := []string{p.txids[4], p.txids[3], p.txids[1], p.txids[0]}
+	if len(pool) != len(want) {
+		t.Fatalf("pool %+v, want %d entries", pool, len(want))
+	}
+	for i, e := range pool {
+		if e.Txid != want[i] || e.Fee != 1000 || e.Vsize != 200 {
+			t.Fatalf("%d: entry %+v, want %s", i, e, want[i])
+		}
+	}
+
+	// cached, only the new one is requested
+	p.mu.Lock()
+	p.txids = append(p.txids, fmt.Sprintf("%064x", 5))
+	delete(p.errs, p.txids[2])
+	p.batches = nil
+	p.mu.Unlock()
+	pool, err = c.RawMempool(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool) != 6 || fmt.Sprint(p.batches) != "[2]" {
+		t.Fatalf("pool %d entries, batches %v", len(pool), p.batches)
+	}
+}
+
+// node failure is not taken as the tx left the pool
+func TestRawMempoolStockError(t *testing.T) {
+	p := newStockPool(t, 3)
+	p.errs[p.txids[1]] = -1
+	c, _ := NewClient(p.URL, "user", "pass")
+	_, err := c.RawMempool(context.Background())
+	if err == nil || !strings.Contains(err.Error(), p.txids[1]) {
+		t.Fatalf("err %v, want the entry error", err)
+	}
+}
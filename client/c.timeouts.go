@@ -0,0 +1,41 @@
+package client
+
+import "time"
+
+// per method deadlines of a single attempt.
+// caller context can only make them shorter
+type Timeouts struct {
+	Default time.Duration
+	Info    time.Duration // getinfo, getbestblock etc, should be fast
+	Mempool time.Duration // getrawmempool, verbose one can take 1+ min for 80k txs
+}
+
+var DefaultTimeouts = Timeouts{
+	Default: 10 * time.Second,
+	Info:    3 * time.Second,
+	Mempool: 2 * time.Minute,
+}
+
+// zero values are left default
+func (c *Client) SetTimeouts(t Timeouts) {
+	if t.Default > 0 {
+		c.timeouts.Default = t.Default
+	}
+	if t.Info > 0 {
+		c.timeouts.Info = t.Info
+	}
+	if t.Mempool > 0 {
+		c.timeouts.Mempool = t.Mempool
+	}
+}
+
+func (t Timeouts) forMethod(method string) time.Duration {
+	switch method {
+	case "getinfo", "getbestblock", "getbestblockhash", "getblockcount",
+		"getblockchaininfo", "getnetworkinfo", "getmempoolinfo":
+		return t.Info
+	case "getrawmempool":
+		return t.Mempool
+	}
+	return t.Default
+}
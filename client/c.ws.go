@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	log "github.com/1F47E/go-feesh/logger"
+	"github.com/fasthttp/websocket"
+)
+
+// btcd websocket RPC notifications
+// https://github.com/btcsuite/btcd/blob/master/docs/json_rpc_api.md#WSExtMethods
+
+// pause before reconnect
+var wsReconnectDelay = 5 * time.Second
+
+// no messages for this long - connection is considered dead
+var wsPingPeriod = 30 * time.Second
+
+type WsHandlers struct {
+	OnTxAccepted        func(t *tx.Transaction)
+	OnBlockConnected    func(hash string, height int)
+	OnBlockDisconnected func(hash string, height int)
+	OnState             func(connected bool)
+}
+
+type WsClient struct {
+	url      string
+	user     string
+	password string
+}
+
+// websocket endpoint of btcd, ws://localhost:18334/ws
+func NewWsClient(url, user, password string) (*WsClient, error) {
+	if url == "" || user == "" || password == "" {
+		return nil, fmt.Errorf("websocket url, user and password must be set")
+	}
+	return &WsClient{
+		url:      url,
+		user:     user,
+		password: password,
+	}, nil
+}
+
+type wsMessage struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Error  interface{}       `json:"error"`
+	Id     *int              `json:"id"`
+}
+
+// connect and subscribe, reconnects until the context is done
+func (w *WsClient) Run(ctx context.Context, h WsHandlers) {
+	l := log.Log.WithField("context", "[RPC WS]")
+	for {
+		err := w.run(ctx, h)
+		if ctx.Err() != nil {
+			return
+		}
+		l.Errorf("websocket error: %v, reconnecting in %s\n", err, wsReconnectDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wsReconnectDelay):
+		}
+	}
+}
+
+func (w *WsClient) run(ctx context.Context, h WsHandlers) error {
+	l := log.Log.WithField("context", "[RPC WS]")
+	header := http.Header{}
+	auth := base64.StdEncoding.EncodeToString([]byte(w.user + ":" + w.password))
+	header.Set("Authorization", "Basic "+auth)
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, w.url, header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// unblock the read on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	// subscriptions are per connection, sent again on every reconnect
+	subs := []*RPCRequest{
+		NewRPCRequest("notifyblocks", []interface{}{}),
+		NewRPCRequest("notifynewtransactions", []interface{}{true}),
+	}
+	for i, r := range subs {
+		r.Id = i + 1
+		if err := conn.WriteJSON(r); err != nil {
+			return err
+		}
+	}
+
+	// keep alive, btcd answers the pings
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * wsPingPeriod))
+	})
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+			}
+		}
+	}()
+
+	connected := false
+	defer func() {
+		if connected && h.OnState != nil {
+			h.OnState(false)
+		}
+	}()
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(2 * wsPingPeriod)); err != nil {
+			return err
+		}
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return err
+		}
+		// subscription response
+		if msg.Id != nil {
+			if msg.Error != nil {
+				return fmt.Errorf("subscription %d error: %v", *msg.Id, msg.Error)
+			}
+			if *msg.Id == len(subs) && !connected {
+				connected = true
+				l.Infof("subscribed to %s\n", w.url)
+				if h.OnState != nil {
+					h.OnState(true)
+				}
+			}
+			continue
+		}
+		w.handle(msg, h)
+	}
+}
+
+func (w *WsClient) handle(msg wsMessage, h WsHandlers) {
+	l := log.Log.WithField("context", "[RPC WS]")
+	switch msg.Method {
+	case "txacceptedverbose":
+		if h.OnTxAccepted == nil || len(msg.Params) < 1 {
+			return
+		}
+		var t tx.Transaction
+		if err := json.Unmarshal(msg.Params[0], &t); err != nil {
+			l.Errorf("error on txacceptedverbose: %v\n", err)
+			return
+		}
+		h.OnTxAccepted(&t)
+	case "blockconnected", "blockdisconnected":
+		// hash, height, time
+		if len(msg.Params) < 2 {
+			return
+		}
+		var hash string
+		var height int
+		if err := json.Unmarshal(msg.Params[0], &hash); err != nil {
+			l.Errorf("error on %s: %v\n", msg.Method, err)
+			return
+		}
+		if err := json.Unmarshal(msg.Params[1], &height); err != nil {
+			l.Errorf("error on %s: %v\n", msg.Method, err)
+			return
+		}
+		if msg.Method == "blockconnected" && h.OnBlockConnected != nil {
+			h.OnBlockConnected(hash, height)
+		}
+		if msg.Method == "blockdisconnected" && h.OnBlockDisconnected != nil {
+			h.OnBlockDisconnected(hash, height)
+		}
+	}
+}
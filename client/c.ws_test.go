@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+)
+
+// btcd stand-in, answers the subscriptions, sends a block and drops the first connection
+type wsNode struct {
+	*httptest.Server
+	mu   *sync.Mutex
+	subs [][]string // methods per connection
+	auth []string
+}
+
+func newWsNode(t *testing.T) *wsNode {
+	t.Helper()
+	n := &wsNode{mu: &sync.Mutex{}}
+	upgrader := websocket.Upgrader{}
+	n.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n.mu.Lock()
+		n.auth = append(n.auth, r.Header.Get("Authorization"))
+		n.subs = append(n.subs, nil)
+		i := len(n.subs) - 1
+		n.mu.Unlock()
+		for j := 0; j < 2; j++ {
+			var req RPCRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			n.mu.Lock()
+			n.subs[i] = append(n.subs[i], req.Method)
+			n.mu.Unlock()
+			_ = conn.WriteJSON(map[string]interface{}{"result": nil, "error": nil, "id": req.Id})
+		}
+		_ = conn.WriteJSON(map[string]interface{}{"method": "blockconnected", "params": []interface{}{"hash", 100 + i, 0}})
+		if i == 0 {
+			return
+		}
+		// keep the next one open till the client is done
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(n.Close)
+	return n
+}
+
+// subscriptions are sent again after the connection is dropped
+func TestWsResubscribe(t *testing.T) {
+	defer func(d time.Duration) { wsReconnectDelay = d }(wsReconnectDelay)
+	wsReconnectDelay = 10 * time.Millisecond
+	node := newWsNode(t)
+	w, _ := NewWsClient("ws"+strings.TrimPrefix(node.URL, "http"), "user", "pass")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	states := make(chan bool, 10)
+	heights := make(chan int, 10)
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, WsHandlers{
+			OnBlockConnected: func(hash string, height int) { heights <- height },
+			OnState:          func(connected bool) { states <- connected },
+		})
+		close(done)
+	}()
+
+	want := []bool{true, false, true}
+	for i, s := range want {
+		select {
+		case got := <-states:
+			if got != s {
+				t.Fatalf("state %d: %v, want %v", i, got, s)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("state %d: timeout", i)
+		}
+	}
+	for _, h := range []int{100, 101} {
+		select {
+		case got := <-heights:
+			if got != h {
+				t.Fatalf("block height %d, want %d", got, h)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("block %d: timeout", h)
+		}
+	}
+
+	node.mu.Lock()
+	subs, auth := node.subs, node.auth
+	node.mu.Unlock()
+	if len(subs) != 2 {
+		t.Fatalf("%d connections, want 2", len(subs))
+	}
+	for i, methods := range subs {
+		if strings.Join(methods, ",") != "notifyblocks,notifynewtransactions" {
+			t.Fatalf("connection %d subscriptions %v", i, methods)
+		}
+		if auth[i] != "Basic dXNlcjpwYXNz" {
+			t.Fatalf("connection %d auth %q", i, auth[i])
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not stopped on cancel")
+	}
+}
@@ -2,10 +2,11 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"time"
 
@@ -45,9 +46,10 @@ type RPCRequest struct {
 	}
 */
 type RPCResponse struct {
-	Jsonrpc string      `json:"jsonrpc"`
-	Result  interface{} `json:"result"`
-	Error   interface{} `json:"error"`
+	Jsonrpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+	Id      int             `json:"id"`
 }
 
 func NewRPCRequest(method string, params interface{}) *RPCRequest {
@@ -60,12 +62,21 @@ func NewRPCRequest(method string, params interface{}) *RPCRequest {
 }
 
 // ===== CLIENT
+
+// keep-alive connections to the node
+const maxIdleConns = 64
+
+// requests in a single batch, RPC_BATCH_SIZE
+const defaultBatchSize = 100
+
 type Client struct {
-	client   *http.Client
-	host     string
-	user     string
-	password string
-	retries  int
+	client    *http.Client
+	backends  *backends
+	retries   int
+	timeouts  Timeouts
+	batchSize int
+	mempool   *mempoolCache
+	recorder  *Recorder
 }
 
 func NewClient(host, user, password string) (*Client, error) {
@@ -74,90 +85,201 @@ func NewClient(host, user, password string) (*Client, error) {
 	}
 	return &Client{
 		client: &http.Client{
-			Timeout: time.Second * 10, // getrawmempool verbose can take a long fucking time
+			// no client timeout, deadlines are set per method, see Timeouts
+			// connections are reused by the parser workers
+			Transport: &http.Transport{
+				MaxIdleConns:        maxIdleConns,
+				MaxIdleConnsPerHost: maxIdleConns,
+				IdleConnTimeout:     90 * time.Second,
+			},
 		},
-		host:     host,
-		user:     user,
-		password: password,
-		retries:  10,
+		backends:  newBackends(newBackend(host, user, password, 0)),
+		retries:   5,
+		timeouts:  DefaultTimeouts,
+		batchSize: defaultBatchSize,
+		mempool:   newMempoolCache(),
 	}, nil
 }
 
-func (c *Client) doRequest(r *RPCRequest) (*RPCResponse, error) {
-	l := log.Log.WithField("context", "[RPC]")
+// rpc error of the response is returned as *RPCError
+func (c *Client) doRequest(ctx context.Context, r *RPCRequest) (*RPCResponse, error) {
 	jr, err := json.Marshal(r)
 	if err != nil {
 		return nil, err
 	}
-	bodyReader := bytes.NewReader(jr)
-
-	req, err := http.NewRequest(http.MethodPost, c.host, bodyReader)
+	data, err := c.post(ctx, c.timeouts.forMethod(r.Method), jr)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.user, c.password)
-	req.Header.Set("Content-Type", "application/json")
-	req.Close = true
+	return decodeResponse(data)
+}
 
-	resp := new(http.Response)
-	for i := 0; i <= c.retries; i++ {
-		resp, err = c.client.Do(req)
-		if err != nil {
-			// retry on 5xx
-			if resp != nil && resp.StatusCode >= 500 {
-				l.Errorf("5xx error: %s", resp.Status)
-				// sleep randomly to not overload the node. Parsing the pool can be 100k+ items.
-				time.Sleep(time.Duration(100*i+rand.Intn(300)) * time.Millisecond)
-				continue
-			}
-			log.Log.Errorf("fatal error: %s", err.Error())
-			return nil, err
-		}
-		defer resp.Body.Close()
-		break
+// request to the specific backend, no failover
+func (c *Client) doRequestTo(ctx context.Context, b *backend, r *RPCRequest) (*RPCResponse, error) {
+	jr, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
 	}
-
-	// debug
-	// read response to bytes
-	data, err := io.ReadAll(resp.Body)
+	data, err := c.postTo(ctx, b, c.timeouts.forMethod(r.Method), jr)
 	if err != nil {
-		log.Log.Errorf("RPC cli reading body err: %s", err.Error())
 		return nil, err
 	}
+	return decodeResponse(data)
+}
 
+func decodeResponse(data []byte) (*RPCResponse, error) {
 	var ret RPCResponse
-	err = json.Unmarshal(data, &ret)
+	err := json.Unmarshal(data, &ret)
 
 	if err != nil {
 		log.Log.Errorf("RPC cli parsing json err: %s\nbody data: %s", err.Error(), string(data))
 		return nil, err
 	}
-
+	if ret.Error != nil {
+		return &ret, ret.Error
+	}
 	return &ret, nil
 }
 
+// send the request body to the active backend.
+// others are tried if it is unavailable
+func (c *Client) post(ctx context.Context, timeout time.Duration, body []byte) ([]byte, error) {
+	var data []byte
+	err := c.failover(ctx, func(b *backend) error {
+		var err error
+		data, err = c.postTo(ctx, b, timeout, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// run the call on the active backend, others are tried if it is unavailable.
+// for the calls that depend on the backend capabilities
+func (c *Client) failover(ctx context.Context, fn func(b *backend) error) error {
+	var lastErr error
+	for _, b := range c.backends.ordered() {
+		err := fn(b)
+		if err == nil {
+			return nil
+		}
+		if !IsUnavailable(err) || ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+		if c.backends.failed(b, err) {
+			log.Log.WithField("context", "[RPC]").Warnf("backend %s failed: %v, switching\n", b.name(), err)
+		}
+	}
+	return lastErr
+}
+
+// send the request body, returns the response body.
+// unavailable node is retried with backoff, timeout is per attempt
+func (c *Client) postTo(ctx context.Context, b *backend, timeout time.Duration, body []byte) ([]byte, error) {
+	l := log.Log.WithField("context", "[RPC]")
+	for attempt := 0; ; attempt++ {
+		data, err := c.postOnce(ctx, b, timeout, body)
+		if err == nil {
+			return data, nil
+		}
+		if !IsUnavailable(err) || errors.Is(err, context.DeadlineExceeded) || attempt >= c.retries {
+			return nil, err
+		}
+		l.Warnf("%s: %v, retry %d\n", b.name(), err, attempt+1)
+		// Parsing the pool can be 100k+ items, do not overload the node
+		if err := sleepCtx(ctx, backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) postOnce(parent context.Context, b *backend, timeout time.Duration, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.host, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(b.user, b.password)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if parent.Err() != nil {
+			return nil, parent.Err()
+		}
+		// slow node is not retried, the same call will be slow again. next backend is tried
+		if ctx.Err() != nil {
+			return nil, &TransportError{Err: fmt.Errorf("no response in %v: %w", timeout, ctx.Err())}
+		}
+		return nil, &TransportError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	// read response to bytes
+	// body is read till the end so the connection can be reused
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &TransportError{Err: err}
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, &HTTPError{Status: resp.StatusCode, Body: "check RPC_USER and RPC_PASSWORD"}
+	}
+	// rpc errors come with 4xx/5xx and the json body, the rest is the node failure
+	// e.g. 503 "Work queue depth exceeded"
+	if resp.StatusCode != http.StatusOK {
+		var r RPCResponse
+		var rs []RPCResponse
+		if json.Unmarshal(data, &r) != nil && json.Unmarshal(data, &rs) != nil {
+			return nil, &HTTPError{Status: resp.StatusCode, Body: string(bytes.TrimSpace(data))}
+		}
+		// node is starting up
+		if r.Error != nil && IsUnavailable(r.Error) {
+			return nil, r.Error
+		}
+	}
+	if c.recorder != nil {
+		c.recorder.Record(start, b.name(), body, data)
+	}
+	return data, nil
+}
+
 // getinfo request
 // curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getinfo","params":[],"id":1}' http://localhost:18334
-func (c *Client) GetInfo() (*info.Info, error) {
-	r := NewRPCRequest("getinfo", []interface{}{})
-	data, err := c.doRequest(r)
+func (c *Client) GetInfo(ctx context.Context) (*info.Info, error) {
+	var ret *info.Info
+	// backup node can be the stock one
+	err := c.failover(ctx, func(b *backend) error {
+		caps, err := c.backendCaps(ctx, b)
+		if err != nil {
+			return err
+		}
+		if !caps.getInfo {
+			ret, err = c.getInfoStock(ctx, b)
+			return err
+		}
+		ret, err = c.getInfoTo(ctx, b)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return ret, nil
+}
 
-	// check type of result
-	if _, ok := data.Result.(map[string]interface{}); !ok {
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
+func (c *Client) getInfoTo(ctx context.Context, b *backend) (*info.Info, error) {
+	r := NewRPCRequest("getinfo", []interface{}{})
+	data, err := c.doRequestTo(ctx, b, r)
 	if err != nil {
 		return nil, err
 	}
 
-	// parse into struct
 	info := new(info.Info)
-	err = json.Unmarshal(rawJson, &info)
+	err = decodeResult(data.Result, '{', &info)
 	if err != nil {
 		return nil, err
 	}
@@ -178,27 +300,35 @@ type ResponseGetBestBlock struct {
 	Height int    `json:"height"`
 }
 
-func (c *Client) GetBestBlock() (*ResponseGetBestBlock, error) {
-	r := NewRPCRequest("getbestblock", []interface{}{})
-	data, err := c.doRequest(r)
+func (c *Client) GetBestBlock(ctx context.Context) (*ResponseGetBestBlock, error) {
+	var ret *ResponseGetBestBlock
+	err := c.failover(ctx, func(b *backend) error {
+		caps, err := c.backendCaps(ctx, b)
+		if err != nil {
+			return err
+		}
+		if !caps.getBestBlock {
+			ret, err = c.getBestBlockStock(ctx, b)
+			return err
+		}
+		ret, err = c.getBestBlockTo(ctx, b)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return ret, nil
+}
 
-	// check type of result
-	if _, ok := data.Result.(map[string]interface{}); !ok {
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
+func (c *Client) getBestBlockTo(ctx context.Context, b *backend) (*ResponseGetBestBlock, error) {
+	r := NewRPCRequest("getbestblock", []interface{}{})
+	data, err := c.doRequestTo(ctx, b, r)
 	if err != nil {
-		log.Log.Errorf("Error marshalling back to raw JSON: %v", err)
 		return nil, err
 	}
 
-	// parse into struct
 	var info ResponseGetBestBlock
-	err = json.Unmarshal(rawJson, &info)
+	err = decodeResult(data.Result, '{', &info)
 	if err != nil {
 		log.Log.Errorf("error unmarshalling response: %v", err)
 		return nil, err
@@ -206,6 +336,21 @@ func (c *Client) GetBestBlock() (*ResponseGetBestBlock, error) {
 	return &info, nil
 }
 
+// get block hash by height
+// curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getblockhash","params":[2443258],"id":1}' http://localhost:18334
+func (c *Client) GetBlockHash(ctx context.Context, height int) (string, error) {
+	r := NewRPCRequest("getblockhash", []interface{}{height})
+	data, err := c.doRequest(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	var hash string
+	if err := decodeResult(data.Result, '"', &hash); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
 // get block header by hash
 // curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getblockheader","params":["0000000000000013d40d7e4cfd271c223c93c134065e3fc857a3adf077da3dda"],"id":1}' http://localhost:18334
 /*
@@ -224,27 +369,15 @@ func (c *Client) GetBestBlock() (*ResponseGetBestBlock, error) {
 }
 */
 
-func (c *Client) GetBlockHeader(hash string) (*block.Block, error) {
+func (c *Client) GetBlockHeader(ctx context.Context, hash string) (*block.Block, error) {
 	r := NewRPCRequest("getblockheader", []interface{}{hash})
-	data, err := c.doRequest(r)
+	data, err := c.doRequest(ctx, r)
 	if err != nil {
 		return nil, err
 	}
 
-	// check type of result
-	if _, ok := data.Result.(map[string]interface{}); !ok {
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
-	if err != nil {
-		log.Log.Errorf("Error marshalling back to raw JSON: %v\n", err)
-		return nil, err
-	}
-
-	// parse into struct
 	ret := new(block.Block)
-	err = json.Unmarshal(rawJson, ret)
+	err = decodeResult(data.Result, '{', ret)
 	if err != nil {
 		log.Log.Errorf("error unmarshalling response: %v\n", err)
 		return nil, err
@@ -284,26 +417,15 @@ func (c *Client) GetBlockHeader(hash string) (*block.Block, error) {
   "previousblockhash": "00000000000019b5d7df02caed57469c2fd082aa78f975de6379e2d9500f8234"
 }
 */
-func (c *Client) GetBlock(blockHash string) (*block.Block, error) {
+func (c *Client) GetBlock(ctx context.Context, blockHash string) (*block.Block, error) {
 	r := NewRPCRequest("getblock", []interface{}{blockHash})
-	data, err := c.doRequest(r)
+	data, err := c.doRequest(ctx, r)
 	if err != nil {
 		log.Log.Errorf("error doing request: %v\n", err)
 		return nil, err
 	}
-	// check type of result
-	if _, ok := data.Result.(map[string]interface{}); !ok {
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
-	if err != nil {
-		return nil, err
-	}
-
-	// parse into struct
 	ret := new(block.Block)
-	err = json.Unmarshal(rawJson, ret)
+	err = decodeResult(data.Result, '{', ret)
 	if err != nil {
 		return nil, err
 	}
@@ -313,7 +435,7 @@ func (c *Client) GetBlock(blockHash string) (*block.Block, error) {
 
 // get transaction
 // curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getrawtransaction","params":["6dcf241891cd43d3508ef6ee8f260fe5a9f3b0337f83874c4123bf6eb2c17454"],"id":1}' http://localhost:18334
-func (c *Client) TransactionGet(txid string) (*tx.Transaction, error) {
+func (c *Client) TransactionGet(ctx context.Context, txid string) (*tx.Transaction, error) {
 	if len(txid) != 64 {
 		return nil, fmt.Errorf("TransactionGet invalid txid")
 	}
@@ -322,24 +444,12 @@ func (c *Client) TransactionGet(txid string) (*tx.Transaction, error) {
 	params := append(p1, p2...)
 
 	r := NewRPCRequest("getrawtransaction", params)
-	data, err := c.doRequest(r)
+	data, err := c.doRequest(ctx, r)
 	if err != nil {
-		return nil, fmt.Errorf("error on getrawtransaction: %v", err)
+		return nil, fmt.Errorf("error on getrawtransaction: %w", err)
 	}
-	// check type of result is string
-	if _, ok := data.Result.(map[string]interface{}); !ok {
-		log.Log.Errorf("data.Result: %v\n", data.Result)
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
-	if err != nil {
-		return nil, fmt.Errorf("error marshalling back to raw JSON: %v", err)
-	}
-
-	// parse into struct
 	var resp tx.Transaction
-	err = json.Unmarshal(rawJson, &resp)
+	err = decodeResult(data.Result, '{', &resp)
 	if err != nil {
 		log.Log.Errorf("error unmarshalling response: %v\n", err)
 		return nil, fmt.Errorf("error unmarshalling response: %v", err)
@@ -371,27 +481,15 @@ func (c *Client) TransactionGet(txid string) (*tx.Transaction, error) {
 // }
 
 // decode raw transaction
-func (c *Client) TransactionDecode(txdata string) (*tx.Transaction, error) {
+func (c *Client) TransactionDecode(ctx context.Context, txdata string) (*tx.Transaction, error) {
 	r := NewRPCRequest("decoderawtransaction", []interface{}{txdata})
-	data, err := c.doRequest(r)
+	data, err := c.doRequest(ctx, r)
 	if err != nil {
 		log.Log.Errorf("error doing request: %v\n", err)
 		return nil, err
 	}
-	// check type of result
-	if _, ok := data.Result.(map[string]interface{}); !ok {
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
-	if err != nil {
-		log.Log.Errorf("Error marshalling back to raw JSON: %v\n", err)
-		return nil, err
-	}
-
-	// parse into struct
 	var resp tx.Transaction
-	err = json.Unmarshal(rawJson, &resp)
+	err = decodeResult(data.Result, '{', &resp)
 	if err != nil {
 		log.Log.Errorf("error unmarshalling response: %v", err)
 		return nil, err
@@ -401,24 +499,14 @@ func (c *Client) TransactionDecode(txdata string) (*tx.Transaction, error) {
 
 // get peer info
 // curl -X POST -H 'Content-Type: application/json' -u 'rpcuser:rpcpass' -d '{"jsonrpc":"1.0","method":"getpeerinfo","params":[],"id":1}' http://localhost:18334
-func (c *Client) GetPeers() ([]*peer.Peer, error) {
+func (c *Client) GetPeers(ctx context.Context) ([]*peer.Peer, error) {
 	r := NewRPCRequest("getpeerinfo", []interface{}{})
-	data, err := c.doRequest(r)
-	if err != nil {
-		return nil, fmt.Errorf("error doing request: %v", err)
-	}
-	// check type of Result
-	if _, ok := data.Result.([]interface{}); !ok {
-		return nil, fmt.Errorf("unexpected type for result")
-	}
-	// Convert back to raw JSON
-	rawJson, err := json.Marshal(data.Result)
+	data, err := c.doRequest(ctx, r)
 	if err != nil {
-		return nil, fmt.Errorf("Error marshalling back to raw JSON: %v", err)
+		return nil, fmt.Errorf("error doing request: %w", err)
 	}
-	// parse into struct
 	var resp []*peer.Peer
-	err = json.Unmarshal(rawJson, &resp)
+	err = decodeResult(data.Result, '[', &resp)
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshalling response: %v", err)
 	}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// node answering getblockhash, after the delay
+func testNode(t *testing.T, delay time.Duration, calls *int32) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		select {
+		case <-time.After(delay):
+		case <-done:
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":"hash","error":null,"id":1}`))
+	}))
+	t.Cleanup(s.Close)
+	// cleanups run in reverse, slow handler returns before the close
+	t.Cleanup(func() { close(done) })
+	return s
+}
+
+// per attempt deadline is the slow node, the next backend is tried
+func TestPostTimeoutFailover(t *testing.T) {
+	var slowCalls, fastCalls int32
+	slow := testNode(t, time.Minute, &slowCalls)
+	fast := testNode(t, 0, &fastCalls)
+	c, _ := NewClient(slow.URL, "user", "pass")
+	c.AddBackend(fast.URL, "user", "pass")
+	c.SetTimeouts(Timeouts{Default: 50 * time.Millisecond})
+
+	hash, err := c.GetBlockHash(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("no failover on timeout: %v", err)
+	}
+	if hash != "hash" {
+		t.Fatalf("hash %q", hash)
+	}
+	// slow call is not retried
+	if n := atomic.LoadInt32(&slowCalls); n != 1 {
+		t.Fatalf("slow node called %d times, want 1", n)
+	}
+	if n := atomic.LoadInt32(&fastCalls); n != 1 {
+		t.Fatalf("fast node called %d times, want 1", n)
+	}
+}
+
+// caller gave up, not the node failure
+func TestPostParentCancel(t *testing.T) {
+	var calls int32
+	slow := testNode(t, time.Minute, &calls)
+	fast := testNode(t, 0, &calls)
+	c, _ := NewClient(slow.URL, "user", "pass")
+	c.AddBackend(fast.URL, "user", "pass")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.GetBlockHash(ctx, 1)
+	if err == nil || IsUnavailable(err) {
+		t.Fatalf("err %v, want the context error", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("nodes called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,49 @@
+/*
+feesh-simnode - btcd compatible JSON-RPC node simulator for the local development.
+generates the evolving mempool, mines blocks on schedule, injects RBF and reorgs.
+
+go run ./cmd/feesh-simnode -listen localhost:18334
+RPC_HOST=http://localhost:18334 RPC_USER=rpcuser RPC_PASS=rpcpass ... go run .
+
+reorg and rbf can be injected on demand:
+curl -u rpcuser:rpcpass -d '{"jsonrpc":"1.0","method":"simreorg","params":[2],"id":1}' http://localhost:18334
+curl -u rpcuser:rpcpass -d '{"jsonrpc":"1.0","method":"simrbf","params":[],"id":1}' http://localhost:18334
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/1F47E/go-feesh/logger"
+)
+
+func main() {
+	listen := flag.String("listen", "localhost:18334", "RPC listen address")
+	user := flag.String("user", "rpcuser", "RPC user")
+	pass := flag.String("pass", "rpcpass", "RPC password")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "random seed, same seed same txs")
+	txRate := flag.Float64("tx-rate", 5, "new txs per second")
+	blockTime := flag.Duration("block-time", time.Minute, "mean time between blocks")
+	premine := flag.Int("premine", 20, "blocks mined on start, coinbase outputs are spent by the txs")
+	rbfEvery := flag.Int("rbf-every", 50, "1 in N new txs replaces a pool tx, 0 to disable")
+	reorgEvery := flag.Int("reorg-every", 0, "every Nth block is a 1-2 blocks reorg, 0 to disable")
+	stock := flag.Bool("stock", false, "stock node: no getinfo/getbestblock, getrawmempool returns txids")
+	flag.Parse()
+
+	log := logger.Log.WithField("context", "[simnode]")
+
+	s := newSim(*seed)
+	for i := 0; i < *premine; i++ {
+		s.mine()
+	}
+	go s.run(context.Background(), *seed, *txRate, *blockTime, *rbfEvery, *reorgEvery)
+
+	srv := &server{sim: s, user: *user, pass: *pass, stock: *stock}
+	log.Infof("listening on %s, stock: %v, seed: %d\n", *listen, *stock, *seed)
+	if err := http.ListenAndServe(*listen, srv); err != nil {
+		log.Fatalf("error on listen: %v", err)
+	}
+}
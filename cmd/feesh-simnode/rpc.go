@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/peer"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+)
+
+type request struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+	Id      interface{}       `json:"id"`
+}
+
+type response struct {
+	Jsonrpc string           `json:"jsonrpc"`
+	Result  interface{}      `json:"result"`
+	Error   *client.RPCError `json:"error"`
+	Id      interface{}      `json:"id"`
+}
+
+var (
+	errMethodNotFound = &client.RPCError{Code: client.RPCMethodNotFound, Message: "Method not found"}
+	errInvalidParams  = &client.RPCError{Code: -32602, Message: "Invalid params"}
+)
+
+type server struct {
+	sim   *sim
+	user  string
+	pass  string
+	stock bool
+}
+
+// single request or the batch array
+func (srv *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if u, p, ok := r.BasicAuth(); !ok || u != srv.user || p != srv.pass {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if b := bytes.TrimLeft(body, " \t\r\n"); len(b) > 0 && b[0] == '[' {
+		var reqs []request
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resps := make([]response, 0, len(reqs))
+		for _, req := range reqs {
+			resps = append(resps, srv.handle(r.Context(), req))
+		}
+		_ = json.NewEncoder(w).Encode(resps)
+		return
+	}
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := srv.handle(r.Context(), req)
+	// same as the node, rpc errors come with non 200 status
+	switch {
+	case resp.Error == nil:
+	case resp.Error.Code == client.RPCMethodNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *server) handle(ctx context.Context, req request) response {
+	resp := response{Jsonrpc: "1.0", Id: req.Id}
+	result, err := srv.call(ctx, req.Method, req.Params)
+	if err != nil {
+		var rpcErr *client.RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &client.RPCError{Code: -1, Message: err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+func param(params []json.RawMessage, i int, ret interface{}) error {
+	if i >= len(params) {
+		return errInvalidParams
+	}
+	if err := json.Unmarshal(params[i], ret); err != nil {
+		return errInvalidParams
+	}
+	return nil
+}
+
+// optional verbose flag, bool or int
+func verbose(params []json.RawMessage, i int) bool {
+	var b bool
+	if param(params, i, &b) == nil {
+		return b
+	}
+	var n int
+	return param(params, i, &n) == nil && n != 0
+}
+
+func (srv *server) call(ctx context.Context, method string, params []json.RawMessage) (interface{}, error) {
+	node := srv.sim.node
+	switch method {
+	case "getinfo":
+		if srv.stock {
+			return nil, errMethodNotFound
+		}
+		return node.GetInfo(ctx)
+	case "getbestblock":
+		if srv.stock {
+			return nil, errMethodNotFound
+		}
+		return node.GetBestBlock(ctx)
+	case "getblockchaininfo":
+		i, err := node.GetInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"chain": "regtest", "blocks": i.Blocks, "difficulty": i.Difficulty}, nil
+	case "getnetworkinfo":
+		i, err := node.GetInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"version": 250000, "protocolversion": 70016, "connections": i.Connections, "relayfee": 0.00001, "warnings": ""}, nil
+	case "getblockcount":
+		best, err := node.GetBestBlock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return best.Height, nil
+	case "getbestblockhash":
+		best, err := node.GetBestBlock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return best.Hash, nil
+	case "getblockhash":
+		var height int
+		if err := param(params, 0, &height); err != nil {
+			return nil, err
+		}
+		return node.GetBlockHash(ctx, height)
+	case "getblockheader":
+		var hash string
+		if err := param(params, 0, &hash); err != nil {
+			return nil, err
+		}
+		return node.GetBlockHeader(ctx, hash)
+	case "getblock":
+		var hash string
+		if err := param(params, 0, &hash); err != nil {
+			return nil, err
+		}
+		return node.GetBlock(ctx, hash)
+	case "getrawtransaction":
+		var txid string
+		if err := param(params, 0, &txid); err != nil {
+			return nil, err
+		}
+		if !verbose(params, 1) {
+			// no raw tx hex, the client uses verbose only
+			return nil, errInvalidParams
+		}
+		return node.TransactionGet(ctx, txid)
+	case "getrawmempool":
+		return srv.rawMempool(ctx, verbose(params, 0))
+	case "getmempoolentry":
+		var txid string
+		if err := param(params, 0, &txid); err != nil {
+			return nil, err
+		}
+		e, err := node.MempoolEntry(ctx, txid)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := node.RawMempool(ctx)
+		if err != nil {
+			return nil, err
+		}
+		best, err := node.GetBestBlock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return srv.verboseEntry(*e, poolSet(pool), best.Height), nil
+	case "getmempoolinfo":
+		pool, err := node.RawMempool(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"size": len(pool)}, nil
+	case "getpeerinfo":
+		peers, err := node.GetPeers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if peers == nil {
+			peers = []*peer.Peer{}
+		}
+		return peers, nil
+	// simulator control
+	case "simreorg":
+		depth := 1
+		_ = param(params, 0, &depth)
+		srv.sim.reorg(depth)
+		return node.GetBestBlock(ctx)
+	case "simrbf":
+		return srv.sim.rbf(), nil
+	case "simmine":
+		srv.sim.mine()
+		return node.GetBestBlock(ctx)
+	}
+	return nil, errMethodNotFound
+}
+
+// patched btcd returns the sorted entries, stock node just txids.
+// verbose is the txid map
+func (srv *server) rawMempool(ctx context.Context, verbose bool) (interface{}, error) {
+	pool, err := srv.sim.node.RawMempool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		best, err := srv.sim.node.GetBestBlock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		inPool := poolSet(pool)
+		ret := make(map[string]txpool.TxPoolVerbose, len(pool))
+		for _, e := range pool {
+			ret[e.Txid] = srv.verboseEntry(e, inPool, best.Height)
+		}
+		return ret, nil
+	}
+	if srv.stock {
+		ret := make([]string, 0, len(pool))
+		for _, e := range pool {
+			ret = append(ret, e.Txid)
+		}
+		return ret, nil
+	}
+	return pool, nil
+}
+
+func poolSet(pool []txpool.TxPool) map[string]bool {
+	ret := make(map[string]bool, len(pool))
+	for _, e := range pool {
+		ret[e.Txid] = true
+	}
+	return ret
+}
+
+// btcd getrawmempool true format, depends are the in-pool parents
+func (srv *server) verboseEntry(e txpool.TxPool, inPool map[string]bool, height int) txpool.TxPoolVerbose {
+	depends := make([]string, 0)
+	srv.sim.mu.Lock()
+	for _, p := range srv.sim.parents[e.Txid] {
+		if inPool[p] {
+			depends = append(depends, p)
+		}
+	}
+	srv.sim.mu.Unlock()
+	return txpool.TxPoolVerbose{
+		Size:    int(e.Size),
+		VSize:   int(e.Vsize),
+		Weight:  int(e.Weight),
+		Fee:     float64(e.Fee) / 1_0000_0000,
+		Time:    e.Time,
+		Height:  height,
+		Depends: depends,
+	}
+}
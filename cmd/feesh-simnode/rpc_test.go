@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+)
+
+// simulated node over http, methods of the requests and batches are counted
+func testServer(t *testing.T, stock bool) (*sim, *httptest.Server, func(method string) int) {
+	t.Helper()
+	s := newSim(1)
+	for i := 0; i < 20; i++ {
+		s.mine()
+	}
+	for i := 0; i < 30; i++ {
+		s.genTx()
+	}
+	srv := &server{sim: s, user: "user", pass: "pass", stock: stock}
+	mu := &sync.Mutex{}
+	calls := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var reqs []request
+		if json.Unmarshal(body, &reqs) != nil {
+			reqs = make([]request, 1)
+			_ = json.Unmarshal(body, &reqs[0])
+		}
+		mu.Lock()
+		for _, req := range reqs {
+			calls[req.Method]++
+		}
+		mu.Unlock()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		srv.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	called := func(method string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[method]
+	}
+	return s, ts, called
+}
+
+// client detects the node kind and reads the chain and the pool with its methods
+func TestServerClient(t *testing.T) {
+	tests := []struct {
+		stock   bool
+		skipped []string // methods of the other kind, not used after the detection
+	}{
+		{false, []string{"getblockchaininfo", "getnetworkinfo", "getbestblockhash", "getmempoolentry"}},
+		{true, []string{"getinfo", "getbestblock"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("stock %v", tt.stock), func(t *testing.T) {
+			ctx := context.Background()
+			s, ts, called := testServer(t, tt.stock)
+			want, _ := s.node.GetBestBlock(ctx)
+			pool, _ := s.node.RawMempool(ctx)
+			// stock info is built from getnetworkinfo
+			nodeInfo, _ := s.node.GetInfo(ctx)
+			version := nodeInfo.Version
+			if tt.stock {
+				version = 250000
+			}
+			if len(pool) == 0 {
+				t.Fatal("empty pool")
+			}
+
+			cli, _ := client.NewClient(ts.URL, "user", "pass")
+			if err := cli.Detect(ctx); err != nil {
+				t.Fatal(err)
+			}
+			before := make(map[string]int)
+			for _, m := range tt.skipped {
+				before[m] = called(m)
+			}
+
+			info, err := cli.GetInfo(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Blocks != want.Height || info.Version != version {
+				t.Fatalf("info %+v, want %d blocks, version %d", info, want.Height, version)
+			}
+			best, err := cli.GetBestBlock(ctx)
+			if err != nil || best.Hash != want.Hash || best.Height != want.Height {
+				t.Fatalf("best block %+v, %v, want %+v", best, err, want)
+			}
+			b, err := cli.GetBlock(ctx, best.Hash)
+			if err != nil || b.Height != want.Height || len(b.Transactions) == 0 {
+				t.Fatalf("block %+v, %v", b, err)
+			}
+
+			got, err := cli.RawMempool(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(pool) {
+				t.Fatalf("pool %d txs, want %d", len(got), len(pool))
+			}
+			fees := make(map[string]uint64, len(pool))
+			txids := make([]string, 0, len(pool))
+			for _, e := range pool {
+				fees[e.Txid] = e.Fee
+				txids = append(txids, e.Txid)
+			}
+			for _, e := range got {
+				if fee, ok := fees[e.Txid]; !ok || e.Fee != fee || e.Vsize == 0 {
+					t.Fatalf("pool entry %+v, want fee %d", e, fee)
+				}
+			}
+			txs, errs, err := cli.TransactionGetMany(ctx, txids)
+			if err != nil || len(errs) != 0 || len(txs) != len(txids) {
+				t.Fatalf("%d txs, errors %v, %v", len(txs), errs, err)
+			}
+
+			for _, m := range tt.skipped {
+				if n := called(m) - before[m]; n != 0 {
+					t.Fatalf("%s called %d times after the detection", m, n)
+				}
+			}
+		})
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	_, ts, _ := testServer(t, false)
+	cli, _ := client.NewClient(ts.URL, "user", "wrong")
+	if _, err := cli.GetBlockHash(context.Background(), 1); err == nil {
+		t.Fatal("no error with the wrong password")
+	}
+}
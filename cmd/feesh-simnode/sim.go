@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/config"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	"github.com/1F47E/go-feesh/logger"
+)
+
+const (
+	coinbaseValue = 625_000_000
+	dustLimit     = 546
+	// ~10% of the txs spend unconfirmed outputs, CPFP chains
+	unconfirmedShare = 0.1
+	// node default ancestor limit
+	maxAncestors = 25
+	// min fee rate bump of the replacement, sat/vB
+	incrementalRate = 1
+)
+
+type outpoint struct {
+	txid  string
+	n     int
+	value uint64
+}
+
+// evolving chain and mempool on top of the in-memory fake node
+type sim struct {
+	node    *client.FakeNode
+	rnd     *rand.Rand
+	mu      *sync.Mutex
+	utxos   []outpoint
+	entries map[string]txpool.TxPool // generated txs by txid, pool ones are put back on reorg
+	parents map[string][]string      // in-pool parents of the pool tx
+	depth   map[string]int           // unconfirmed chain length, parents mined later are not accounted
+	nonce   int
+}
+
+func newSim(seed int64) *sim {
+	return &sim{
+		node:    client.NewFakeNode(),
+		rnd:     rand.New(rand.NewSource(seed)),
+		mu:      &sync.Mutex{},
+		utxos:   make([]outpoint, 0),
+		entries: make(map[string]txpool.TxPool),
+		parents: make(map[string][]string),
+		depth:   make(map[string]int),
+	}
+}
+
+func (s *sim) newTxid() string {
+	s.nonce++
+	sum := sha256.Sum256([]byte("feesh-simnode:" + strconv.Itoa(s.nonce)))
+	return hex.EncodeToString(sum[:])
+}
+
+// fee rate in sat/vB, log-normal around a few sat/vB with the long tail
+func (s *sim) feeRate() float64 {
+	r := math.Exp(s.rnd.NormFloat64()*1.1 + 2.0)
+	if r < 1 {
+		r = 1
+	}
+	return math.Round(r*10) / 10
+}
+
+func (s *sim) takeUtxo() (outpoint, bool) {
+	if len(s.utxos) == 0 {
+		return outpoint{}, false
+	}
+	i := s.rnd.Intn(len(s.utxos))
+	// coinbase outputs are prepended, the new ones appended.
+	// prefer the older ones, mostly confirmed
+	if s.rnd.Float64() > unconfirmedShare {
+		i = s.rnd.Intn(len(s.utxos)/2 + 1)
+	}
+	o := s.utxos[i]
+	s.utxos[i] = s.utxos[len(s.utxos)-1]
+	s.utxos = s.utxos[:len(s.utxos)-1]
+	return o, true
+}
+
+// new pool tx spending random outputs
+func (s *sim) genTx() *tx.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nIn := 1 + s.rnd.Intn(2)
+	ins := make([]outpoint, 0, nIn)
+	for i := 0; i < nIn; i++ {
+		o, ok := s.takeUtxo()
+		if !ok {
+			break
+		}
+		ins = append(ins, o)
+	}
+	if len(ins) == 0 {
+		return nil
+	}
+	return s.buildTx(ins, s.feeRate(), "")
+}
+
+// tx spending the inputs with the fee rate, added to the pool.
+// pool entry time is kept for the replacement
+func (s *sim) buildTx(ins []outpoint, rate float64, replaces string) *tx.Transaction {
+	var in uint64
+	for _, o := range ins {
+		in += o.value
+	}
+	segwit := s.rnd.Float64() < 0.8
+	taproot := segwit && s.rnd.Float64() < 0.3
+	nOut := 1 + s.rnd.Intn(3)
+
+	// sizes of the typical p2wpkh/p2pkh spends
+	base := 10 + 41*len(ins) + 31*nOut
+	witness := 0
+	if segwit {
+		witness = 2 + 108*len(ins)
+	} else {
+		base += 107 * len(ins)
+	}
+	weight := base*4 + witness
+	vsize := (weight + 3) / 4
+	fee := uint64(math.Ceil(rate * float64(vsize)))
+	// replacement pays for its own relay on top of the old fee
+	if old, ok := s.entries[replaces]; ok && fee < old.Fee+uint64(incrementalRate*vsize) {
+		fee = old.Fee + uint64(incrementalRate*vsize)
+	}
+	parents := make([]string, 0)
+	depth := 1
+	for _, o := range ins {
+		if _, ok := s.entries[o.txid]; ok && s.inPool(o.txid) {
+			parents = append(parents, o.txid)
+			if s.depth[o.txid]+1 > depth {
+				depth = s.depth[o.txid] + 1
+			}
+		}
+	}
+	if in < fee+uint64(nOut)*dustLimit || depth > maxAncestors {
+		// not worth spending, give the coins back
+		s.utxos = append(s.utxos, ins...)
+		return nil
+	}
+
+	t := &tx.Transaction{
+		Txid:    s.newTxid(),
+		Version: 2,
+		Size:    base + witness,
+		Weight:  weight,
+	}
+	for _, o := range ins {
+		vin := tx.Vin{Txid: o.txid, Vout: o.n, Sequence: 0xfffffffd}
+		if segwit {
+			vin.Txinwitness = []string{"30440220" + o.txid[:56] + "01", "02" + o.txid}
+			if taproot {
+				vin.Txinwitness = []string{o.txid + o.txid}
+			}
+		}
+		t.Vin = append(t.Vin, vin)
+	}
+	left := in - fee
+	for n := 0; n < nOut; n++ {
+		value := left
+		if n < nOut-1 {
+			value = dustLimit + uint64(s.rnd.Int63n(int64(left-uint64(nOut-n)*dustLimit)+1))
+		}
+		left -= value
+		spk := tx.ScriptPubKey{Type: "witness_v0_keyhash"}
+		if taproot {
+			spk.Type = "witness_v1_taproot"
+		} else if !segwit {
+			spk.Type = "pubkeyhash"
+		}
+		t.Vout = append(t.Vout, tx.Vout{Value: float64(value) / 1_0000_0000, N: n, ScriptPubKey: spk})
+		s.utxos = append(s.utxos, outpoint{txid: t.Txid, n: n, value: value})
+	}
+
+	e := txpool.TxPool{
+		Time:   time.Now().Unix(),
+		Size:   uint32(t.Size),
+		Vsize:  uint32(vsize),
+		Weight: uint32(weight),
+		Fee:    fee,
+	}
+	if old, ok := s.entries[replaces]; ok {
+		e.Time = old.Time
+	}
+	e.FeePerKB = e.Fee * 1000 / uint64(e.Size)
+	e.Txid = t.Txid
+	s.entries[t.Txid] = e
+	s.parents[t.Txid] = parents
+	s.depth[t.Txid] = depth
+	s.node.AddPoolTx(t, e)
+	return t
+}
+
+func (s *sim) inPool(txid string) bool {
+	_, err := s.node.MempoolEntry(context.Background(), txid)
+	return err == nil
+}
+
+// pool txs by fee rate with the parents first, up to the block weight
+func (s *sim) template() []string {
+	pool, _ := s.node.RawMempool(context.Background())
+	sort.Slice(pool, func(i, j int) bool {
+		return pool[i].FeeRate() > pool[j].FeeRate()
+	})
+	inPool := make(map[string]bool, len(pool))
+	for _, e := range pool {
+		inPool[e.Txid] = true
+	}
+	added := make(map[string]bool)
+	ret := make([]string, 0)
+	weight := 4000 // header and coinbase
+	for _, e := range pool {
+		if weight+int(e.Weight) > config.BLOCK_SIZE {
+			continue
+		}
+		ok := true
+		for _, p := range s.parents[e.Txid] {
+			if inPool[p] && !added[p] {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		added[e.Txid] = true
+		weight += int(e.Weight)
+		ret = append(ret, e.Txid)
+	}
+	return ret
+}
+
+func (s *sim) mine() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	txids := s.template()
+	b := s.node.Mine(txids...)
+	s.utxos = append([]outpoint{{txid: b.Transactions[0], n: 0, value: coinbaseValue}}, s.utxos...)
+	logger.Log.WithField("context", "[simnode]").Infof("mined block %d %s, txs: %d\n", b.Height, b.Hash, len(b.Transactions))
+}
+
+// replace a random pool tx without the in-pool children with the higher fee one
+func (s *sim) rbf() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pool, _ := s.node.RawMempool(context.Background())
+	if len(pool) == 0 {
+		return false
+	}
+	hasChildren := make(map[string]bool)
+	for _, e := range pool {
+		for _, p := range s.parents[e.Txid] {
+			hasChildren[p] = true
+		}
+	}
+	for tries := 0; tries < 10; tries++ {
+		old := pool[s.rnd.Intn(len(pool))]
+		if hasChildren[old.Txid] {
+			continue
+		}
+		t, err := s.node.TransactionGet(context.Background(), old.Txid)
+		if err != nil {
+			continue
+		}
+		// inputs back from the replaced tx, its outputs are gone
+		ins := make([]outpoint, 0, len(t.Vin))
+		for _, vin := range t.Vin {
+			ins = append(ins, s.outValue(vin.Txid, vin.Vout))
+		}
+		s.dropOutputs(old.Txid)
+		s.node.RemovePoolTx(old.Txid)
+		rate := old.FeeRate()*1.5 + 1
+		if n := s.buildTx(ins, rate, old.Txid); n != nil {
+			logger.Log.WithField("context", "[simnode]").Infof("replaced %s with %s\n", old.Txid, n.Txid)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *sim) outValue(txid string, n int) outpoint {
+	o := outpoint{txid: txid, n: n}
+	if t, err := s.node.TransactionGet(context.Background(), txid); err == nil && n < len(t.Vout) {
+		o.value = tx.BtcToSat(t.Vout[n].Value)
+	}
+	return o
+}
+
+func (s *sim) dropOutputs(txid string) {
+	utxos := s.utxos[:0]
+	for _, o := range s.utxos {
+		if o.txid != txid {
+			utxos = append(utxos, o)
+		}
+	}
+	s.utxos = utxos
+}
+
+// replace the last blocks with the longer fork, stale txs go back to the pool
+func (s *sim) reorg(depth int) {
+	s.mu.Lock()
+	ctx := context.Background()
+	best, _ := s.node.GetBestBlock(ctx)
+	if depth < 1 || depth >= best.Height {
+		s.mu.Unlock()
+		return
+	}
+	stale := make([]string, 0)
+	hash := best.Hash
+	for i := 0; i < depth; i++ {
+		b, err := s.node.GetBlock(ctx, hash)
+		if err != nil {
+			break
+		}
+		// oldest block first, parents before children
+		stale = append(append([]string{}, b.Transactions[1:]...), stale...)
+		s.dropOutputs(b.Transactions[0])
+		hash = b.Previousblockhash
+	}
+	s.node.Reorg(depth)
+	for _, txid := range stale {
+		t, err := s.node.TransactionGet(ctx, txid)
+		if err != nil {
+			continue
+		}
+		s.node.AddPoolTx(t, s.entries[txid])
+	}
+	s.mu.Unlock()
+	logger.Log.WithField("context", "[simnode]").Warnf("reorg at height %d, depth %d\n", best.Height-depth+1, depth)
+	for i := 0; i <= depth; i++ {
+		s.mine()
+	}
+}
+
+// tx arrivals, blocks and the injected events on schedule
+// schedule has its own rand, the sim one is used under the lock
+func (s *sim) run(ctx context.Context, seed int64, txRate float64, blockTime time.Duration, rbfEvery, reorgEvery int) {
+	rnd := rand.New(rand.NewSource(seed + 1))
+	blocks := time.NewTimer(nextBlock(rnd, blockTime))
+	defer blocks.Stop()
+	txs := time.NewTicker(100 * time.Millisecond)
+	defer txs.Stop()
+	mined := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-txs.C:
+			// poisson arrivals per tick
+			n := poisson(rnd, txRate/10)
+			for i := 0; i < n; i++ {
+				s.genTx()
+				if rbfEvery > 0 && rnd.Intn(rbfEvery) == 0 {
+					s.rbf()
+				}
+			}
+		case <-blocks.C:
+			mined++
+			if reorgEvery > 0 && mined%reorgEvery == 0 {
+				s.reorg(1 + rnd.Intn(2))
+			} else {
+				s.mine()
+			}
+			blocks.Reset(nextBlock(rnd, blockTime))
+		}
+	}
+}
+
+// exponential block intervals
+func nextBlock(rnd *rand.Rand, mean time.Duration) time.Duration {
+	return time.Duration(rnd.ExpFloat64() * float64(mean))
+}
+
+func poisson(rnd *rand.Rand, lambda float64) int {
+	l := math.Exp(-lambda)
+	k := 0
+	p := 1.0
+	for {
+		p *= rnd.Float64()
+		if p <= l {
+			return k
+		}
+		k++
+	}
+}
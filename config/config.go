@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	log "github.com/1F47E/go-feesh/logger"
 )
@@ -13,9 +14,34 @@ type Config struct {
 	RpcUser            string
 	RpcPass            string
 	RpcHost            string
+	RpcBackends        []string // backup nodes, RPC_USER and RPC_PASSWORD are used if no credentials in the url
 	ApiHost            string
 	RpcLimit           int // btc node config should be updated to allow more connections
+	RpcBatchSize       int // requests in a single JSON-RPC batch
+	RpcTimeout         int // seconds per RPC call
+	RpcTimeoutInfo     int // seconds for getinfo, getbestblock etc
+	RpcTimeoutMempool  int // seconds for getrawmempool, verbose one is slow on the big pool
 	BlocksParsingDepth int
+	PrevoutCacheSize   int // txs with out amounts to keep for block tx fee calc
+	// historical blocks backfill. forward from height if set, otherwise backward from tip by depth
+	BackfillFromHeight int
+	BackfillDepth      int
+	BackfillTxRate     int      // txs per second, every tx is 1+ RPC calls
+	PoolsFile          string   // mining pools table, bundled one is used if empty
+	ZmqAddress         string   // bitcoin core zmqpub address, polling only if empty
+	WsHost             string   // btcd websocket RPC, polling only if empty
+	P2PPeers           []string // host:port to listen over p2p, comma separated in the env
+	P2PNetwork         string   // mainnet, testnet3, regtest, signet, simnet
+	ReconcilePeriod    int      // seconds between the polls while the updates are pushed
+	RecordFile         string   // RPC session recording, gzip json lines
+	ReplayFile         string   // recording served instead of the node
+	ReplaySpeed        int      // 1 is the original timeline
+	Storage            string   // map (in memory) or redis
+	RedisAddr          string
+	RedisPassword      string
+	RedisDB            int
+	RedisPrefix        string // keys prefix, to share the db
+	RedisTxTTL         int    // hours to keep the parsed txs
 }
 
 func NewConfig() *Config {
@@ -62,8 +88,64 @@ func NewConfig() *Config {
 		RpcUser:            rpcUser,
 		RpcPass:            rpcPass,
 		RpcHost:            rpcHost,
+		RpcBackends:        getEnvList("RPC_BACKENDS"),
 		RpcLimit:           rpcLimit,
+		RpcBatchSize:       getEnvInt("RPC_BATCH_SIZE", 100),
+		RpcTimeout:         getEnvInt("RPC_TIMEOUT_SECONDS", 10),
+		RpcTimeoutInfo:     getEnvInt("RPC_TIMEOUT_INFO_SECONDS", 3),
+		RpcTimeoutMempool:  getEnvInt("RPC_TIMEOUT_MEMPOOL_SECONDS", 120),
 		ApiHost:            apiHost,
 		BlocksParsingDepth: blocksDepth,
+		PrevoutCacheSize:   getEnvInt("PREVOUT_CACHE_SIZE", 100_000),
+		BackfillFromHeight: getEnvInt("BACKFILL_FROM_HEIGHT", 0),
+		BackfillDepth:      getEnvInt("BACKFILL_DEPTH", 0),
+		BackfillTxRate:     getEnvInt("BACKFILL_TX_RATE", 50),
+		PoolsFile:          os.Getenv("POOLS_FILE"),
+		ZmqAddress:         os.Getenv("ZMQ_ADDRESS"),
+		WsHost:             os.Getenv("RPC_WS_HOST"),
+		P2PPeers:           getEnvList("P2P_PEERS"),
+		P2PNetwork:         os.Getenv("P2P_NETWORK"),
+		ReconcilePeriod:    getEnvInt("RECONCILE_SECONDS", 30),
+		RecordFile:         os.Getenv("RPC_RECORD_FILE"),
+		ReplayFile:         os.Getenv("RPC_REPLAY_FILE"),
+		ReplaySpeed:        getEnvInt("RPC_REPLAY_SPEED", 1),
+		Storage:            getEnvStr("STORAGE", "map"),
+		RedisAddr:          getEnvStr("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:      os.Getenv("REDIS_PASSWORD"),
+		RedisDB:            getEnvInt("REDIS_DB", 0),
+		RedisPrefix:        getEnvStr("REDIS_PREFIX", "feesh:"),
+		RedisTxTTL:         getEnvInt("REDIS_TX_TTL_HOURS", 336),
 	}
 }
+
+// optional env var with default value
+func getEnvStr(name string, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// optional int env var with default value
+func getEnvInt(name string, def int) int {
+	str := os.Getenv(name)
+	if str == "" {
+		return def
+	}
+	val, err := strconv.Atoi(str)
+	if err != nil {
+		log.Log.Fatalf("error on parse %s env var: %v", name, err)
+	}
+	return val
+}
+
+// optional comma separated env var
+func getEnvList(name string) []string {
+	ret := make([]string, 0)
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// pause after failed block before retry
+var backfillRetryDelay = 10 * time.Second
+
+// parse historical blocks and store the stats.
+// progress is saved after every block and resumed on restart
+func (c *Core) bootstrap() {
+	log := logger.Log.WithField("context", "[bootstrap]")
+	if c.Cfg.BackfillFromHeight <= 0 && c.Cfg.BackfillDepth <= 0 {
+		return
+	}
+	log.Info("started")
+	defer func() {
+		log.Info("stopped")
+	}()
+
+	best, err := c.cli.GetBestBlock(c.ctx)
+	if err != nil {
+		log.Errorf("error on getbestblock: %v\n", err)
+		return
+	}
+	progress, err := c.backfillPlan(best.Height)
+	if err != nil {
+		log.Errorf("error on backfill plan: %v\n", err)
+		return
+	}
+	c.setBackfill(progress)
+	if progress.Finished {
+		log.Infof("backfill already finished at height %d\n", progress.TargetHeight)
+		return
+	}
+	log.Infof("backfill %s from %d to %d, next %d\n", progress.Direction, progress.StartHeight, progress.TargetHeight, progress.NextHeight)
+
+	rate := c.Cfg.BackfillTxRate
+	if rate < 1 {
+		rate = 1
+	}
+	throttle := time.NewTicker(time.Second / time.Duration(rate))
+	defer throttle.Stop()
+
+	sessionStart := time.Now()
+	sessionDone := 0
+	for !progress.Finished {
+		height := progress.NextHeight
+		err := c.backfillBlock(height, throttle.C)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			// historical txs are only available with the tx index, retry will not help
+			if client.IsNotFound(err) {
+				log.Errorf("error on block %d: %v, node should run with txindex\n", height, err)
+				return
+			}
+			log.Errorf("error on block %d: %v\n", height, err)
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(backfillRetryDelay):
+			}
+			continue
+		}
+		progress.Done++
+		sessionDone++
+		if progress.Direction == mblock.BackfillForward {
+			progress.NextHeight++
+			progress.Finished = progress.NextHeight > progress.TargetHeight
+		} else {
+			progress.NextHeight--
+			progress.Finished = progress.NextHeight < progress.TargetHeight
+		}
+		progress.UpdatedAt = time.Now()
+		// eta by the speed of this run
+		perBlock := time.Since(sessionStart) / time.Duration(sessionDone)
+		progress.EtaSeconds = int64((perBlock * time.Duration(progress.Total-progress.Done)).Seconds())
+		c.setBackfill(progress)
+		log.Debugf("block %d done, %d/%d\n", height, progress.Done, progress.Total)
+	}
+	log.Infof("backfill finished, %d blocks\n", progress.Done)
+}
+
+// resume stored progress or start the new one
+func (c *Core) backfillPlan(tip int) (mblock.Backfill, error) {
+	direction := mblock.BackfillBackward
+	if c.Cfg.BackfillFromHeight > 0 {
+		direction = mblock.BackfillForward
+	}
+	stored, err := c.storage.BackfillGet()
+	if err != nil {
+		return mblock.Backfill{}, err
+	}
+	if stored != nil && stored.Direction == direction {
+		// forward backfill follows the tip
+		if stored.Finished && direction == mblock.BackfillForward && tip > stored.TargetHeight {
+			stored.Total += tip - stored.TargetHeight
+			stored.TargetHeight = tip
+			stored.Finished = false
+		}
+		return *stored, nil
+	}
+
+	p := mblock.Backfill{
+		Direction: direction,
+		StartedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if direction == mblock.BackfillForward {
+		p.StartHeight = c.Cfg.BackfillFromHeight
+		p.TargetHeight = tip
+	} else {
+		p.StartHeight = tip
+		p.TargetHeight = tip - c.Cfg.BackfillDepth + 1
+		if p.TargetHeight < 0 {
+			p.TargetHeight = 0
+		}
+	}
+	p.NextHeight = p.StartHeight
+	p.Total = p.TargetHeight - p.StartHeight
+	if p.Total < 0 {
+		p.Total = -p.Total
+	}
+	p.Total++
+	p.Finished = direction == mblock.BackfillForward && p.StartHeight > tip
+	return p, nil
+}
+
+// parse all the block txs and store the block stats
+func (c *Core) backfillBlock(height int, throttle <-chan time.Time) error {
+	hash, err := c.cli.GetBlockHash(c.ctx, height)
+	if err != nil {
+		return fmt.Errorf("error on getblockhash: %w", err)
+	}
+	c.deleteOrphanedStats(map[int]string{height: hash})
+	exists, err := c.storage.BlockStatsGet(hash)
+	if err != nil {
+		return fmt.Errorf("error on blockstatsget: %w", err)
+	}
+	if exists != nil {
+		return nil
+	}
+	b, err := c.cli.GetBlock(c.ctx, hash)
+	if err != nil {
+		return fmt.Errorf("error on getblock: %w", err)
+	}
+	stored, err := c.storage.TxGetMany(b.Transactions)
+	if err != nil {
+		return fmt.Errorf("error on txgetmany: %w", err)
+	}
+	txs := make([]*mtx.Tx, 0, len(b.Transactions))
+	missing := make([]string, 0)
+	for i, tx := range stored {
+		if tx == nil || !tx.IsComplete() {
+			missing = append(missing, b.Transactions[i])
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	// historical txs are not stored, only the block stats
+	for len(missing) > 0 {
+		n := c.Cfg.RpcBatchSize
+		if n < 1 || n > len(missing) {
+			n = len(missing)
+		}
+		// throttle the node, a tick per tx of the batch
+		for i := 0; i < n; i++ {
+			select {
+			case <-c.ctx.Done():
+				return c.ctx.Err()
+			case <-throttle:
+			}
+		}
+		for i, res := range c.parseTxs(missing[:n]) {
+			if res.err != nil {
+				return fmt.Errorf("error on parsing tx %s: %w", missing[i], res.err)
+			}
+			txs = append(txs, res.tx)
+		}
+		missing = missing[n:]
+	}
+	stats := newBlockStats(b, c.prevBlockTime(b), len(b.Transactions), txs)
+	return c.storage.BlockStatsAdd(stats)
+}
+
+func (c *Core) setBackfill(p mblock.Backfill) {
+	c.mu.Lock()
+	c.backfill = &p
+	c.mu.Unlock()
+	if err := c.storage.BackfillSet(p); err != nil {
+		logger.Log.Errorf("error on backfillset: %v\n", err)
+	}
+}
+
+// backfill progress, nil if not configured
+func (c *Core) GetBackfill() *mblock.Backfill {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.backfill == nil {
+		return nil
+	}
+	p := *c.backfill
+	return &p
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+)
+
+// backfill the block with the given throttle ticks, fails if it waits for more
+func backfillWithTicks(t *testing.T, c *Core, height int, ticks int) {
+	t.Helper()
+	throttle := make(chan time.Time, ticks)
+	for i := 0; i < ticks; i++ {
+		throttle <- time.Now()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.backfillBlock(height, throttle)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("backfill of %d waits for more than %d ticks", height, ticks)
+	}
+	if left := len(throttle); left != 0 {
+		t.Fatalf("backfill of %d used %d ticks, want %d", height, ticks-left, ticks)
+	}
+}
+
+// the throttle is for the node, stored txs are not waited for
+func TestBackfillThrottle(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	a, b := testTx(0, funding), testTx(1, funding)
+	node.AddTx(a)
+	node.AddTx(b)
+	blk := node.Mine(a.Txid, b.Txid)
+	c := newTestCore(t, testConfig(), node)
+
+	// coinbase and 2 txs from the node
+	backfillWithTicks(t, c, blk.Height, 3)
+	stats, _ := c.storage.BlockStatsGet(blk.Hash)
+	if stats == nil || !stats.IsComplete() || stats.Fee != 2*900_000 {
+		t.Fatalf("backfilled stats %+v", stats)
+	}
+
+	// parsed by the block workers, all the txs are stored
+	tick(c)
+	_ = c.storage.BlockStatsDelete(blk.Hash)
+	backfillWithTicks(t, c, blk.Height, 0)
+	if stats, _ := c.storage.BlockStatsGet(blk.Hash); stats == nil || !stats.IsComplete() {
+		t.Fatalf("backfilled stats %+v", stats)
+	}
+}
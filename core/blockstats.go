@@ -0,0 +1,80 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/1F47E/go-feesh/entity/btc/block"
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+// block stats from the parsed txs.
+// txs are the complete ones only, total is the number of txs in the block.
+// prevTime is the time of the previous block, 0 if unknown
+func newBlockStats(hdr *block.Block, prevTime int, total int, txs []*mtx.Tx) mblock.Block {
+	b := mblock.Block{
+		Hash:      hdr.Hash,
+		Height:    hdr.Height,
+		Time:      int64(hdr.Time),
+		Txs:       uint64(total),
+		TxsParsed: uint64(len(txs)),
+	}
+	if prevTime > 0 {
+		b.Interval = int64(hdr.Time - prevTime)
+	}
+	rates := make([]float64, 0, len(txs))
+	var segwit, taproot int
+	for _, tx := range txs {
+		b.Weight += uint64(tx.Weight)
+		b.Size += uint64(tx.Size)
+		b.VSize += uint64(tx.VSize())
+		if tx.Coinbase {
+			b.Reward = tx.AmountOut
+			b.Pool = tx.Pool
+			continue
+		}
+		b.Fee += tx.Fee
+		b.Value += tx.AmountOut
+		rates = append(rates, tx.FeePerVByte())
+		if tx.Segwit {
+			segwit++
+		}
+		if tx.Taproot {
+			taproot++
+		}
+	}
+	// claimed new coins, the same on every network. known once every fee is
+	if b.IsComplete() && b.Reward >= b.Fee {
+		b.Subsidy = b.Reward - b.Fee
+	}
+	if len(rates) > 0 {
+		sort.Float64s(rates)
+		pct := percentiles(rates)
+		b.FeeRateMin = rates[0]
+		b.FeeRateP10 = pct.P10
+		b.FeeRateMedian = pct.P50
+		b.FeeRateP90 = pct.P90
+		b.FeeRateMax = rates[len(rates)-1]
+		b.SegwitShare = float64(segwit) / float64(len(rates))
+		b.TaprootShare = float64(taproot) / float64(len(rates))
+	}
+	return b
+}
+
+// time of the previous block, from the parsed headers or the node
+func (c *Core) prevBlockTime(hdr *block.Block) int {
+	if hdr.Previousblockhash == "" {
+		return 0
+	}
+	c.mu.Lock()
+	prev, ok := c.blockHeaders[hdr.Previousblockhash]
+	c.mu.Unlock()
+	if ok {
+		return prev.Time
+	}
+	prev, err := c.cli.GetBlockHeader(c.ctx, hdr.Previousblockhash)
+	if err != nil {
+		return 0
+	}
+	return prev.Time
+}
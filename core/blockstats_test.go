@@ -0,0 +1,45 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/1F47E/go-feesh/entity/btc/block"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+func TestNewBlockStats(t *testing.T) {
+	coinbase := &mtx.Tx{Hash: "cb", Coinbase: true, AmountOut: 312_500_000 + 6300, Weight: 400, Pool: "pool"}
+	// 10, 3 and 50 sat/vB, not in the order of the rates
+	txs := []*mtx.Tx{
+		{Hash: "a", Fee: 1000, Weight: 400, Segwit: true},
+		{Hash: "b", Fee: 300, Weight: 400},
+		{Hash: "c", Fee: 5000, Weight: 400, Segwit: true, Taproot: true},
+	}
+	hdr := &block.Block{Hash: "h", Height: 840_000, Time: 1_700_000_600}
+	tests := []struct {
+		name    string
+		total   int
+		txs     []*mtx.Tx
+		subsidy uint64
+	}{
+		{"complete", 4, append([]*mtx.Tx{coinbase}, txs...), 312_500_000},
+		{"not parsed yet", 5, append([]*mtx.Tx{coinbase}, txs...), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBlockStats(hdr, 1_700_000_000, tt.total, tt.txs)
+			if b.Subsidy != tt.subsidy {
+				t.Fatalf("subsidy %d, want %d", b.Subsidy, tt.subsidy)
+			}
+			if b.Fee != 6300 || b.Reward != coinbase.AmountOut || b.Pool != "pool" || b.Interval != 600 {
+				t.Fatalf("stats %+v", b)
+			}
+			if b.FeeRateMin != 3 || b.FeeRateMedian != 10 || b.FeeRateMax != 50 {
+				t.Fatalf("fee rates min %v median %v max %v, want 3 10 50", b.FeeRateMin, b.FeeRateMedian, b.FeeRateMax)
+			}
+			if b.SegwitShare != 2.0/3 || b.TaprootShare != 1.0/3 {
+				t.Fatalf("segwit %v taproot %v", b.SegwitShare, b.TaprootShare)
+			}
+		})
+	}
+}
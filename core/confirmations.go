@@ -0,0 +1,130 @@
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	mremoval "github.com/1F47E/go-feesh/entity/models/removal"
+	mstats "github.com/1F47E/go-feesh/entity/models/stats"
+)
+
+// samples kept per fee band
+var confirmationSamplesLimit = 1000
+
+type confirmationSample struct {
+	txid       string
+	seenHeight int
+	blocks     int
+	seconds    float64
+}
+
+// confirmationStats joins the pool first seen with the block the tx was confirmed in
+type confirmationStats struct {
+	mu        *sync.Mutex
+	startedAt time.Time
+	seenAt    map[string]int // txid -> block height when first seen
+	samples   [][]confirmationSample
+}
+
+func newConfirmationStats() *confirmationStats {
+	return &confirmationStats{
+		mu:        &sync.Mutex{},
+		startedAt: time.Now(),
+		seenAt:    make(map[string]int),
+		samples:   make([][]confirmationSample, len(buckets)),
+	}
+}
+
+// new pool txs at the current height.
+// txs that were in the pool before the start are skipped, real wait time is unknown
+func (s *confirmationStats) Seen(txid string, poolTime time.Time, height int) {
+	if poolTime.Before(s.startedAt) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seenAt[txid]; !ok {
+		s.seenAt[txid] = height
+	}
+}
+
+// record the wait of the confirmed tx, forget the rest
+func (s *confirmationStats) Add(rm mremoval.Removal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	height, ok := s.seenAt[rm.Txid]
+	delete(s.seenAt, rm.Txid)
+	if !ok || rm.Reason != mremoval.ReasonConfirmed {
+		return
+	}
+	blocks := rm.BlockHeight - height
+	if blocks < 1 {
+		blocks = 1
+	}
+	i := bucketIndex(uint(rm.FeeRate))
+	s.samples[i] = append(s.samples[i], confirmationSample{
+		txid:       rm.Txid,
+		seenHeight: height,
+		blocks:     blocks,
+		seconds:    rm.Time.Sub(rm.FirstSeen).Seconds(),
+	})
+	if len(s.samples[i]) > confirmationSamplesLimit {
+		s.samples[i] = s.samples[i][len(s.samples[i])-confirmationSamplesLimit:]
+	}
+}
+
+// confirmation of the orphaned block, the tx waits for the new one
+func (s *confirmationStats) Revert(rm mremoval.Removal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := bucketIndex(uint(rm.FeeRate))
+	for j, sm := range s.samples[i] {
+		if sm.txid != rm.Txid {
+			continue
+		}
+		s.samples[i] = append(s.samples[i][:j], s.samples[i][j+1:]...)
+		s.seenAt[rm.Txid] = sm.seenHeight
+		return
+	}
+}
+
+// percentiles per fee band, bands without samples are skipped
+func (s *confirmationStats) Get() []mstats.ConfirmationTimes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]mstats.ConfirmationTimes, 0)
+	for i, samples := range s.samples {
+		if len(samples) == 0 {
+			continue
+		}
+		blocks := make([]float64, len(samples))
+		seconds := make([]float64, len(samples))
+		for j, sm := range samples {
+			blocks[j] = float64(sm.blocks)
+			seconds[j] = sm.seconds
+		}
+		res = append(res, mstats.ConfirmationTimes{
+			FeeRate: buckets[i],
+			Samples: len(samples),
+			Blocks:  percentiles(blocks),
+			Seconds: percentiles(seconds),
+		})
+	}
+	return res
+}
+
+func percentiles(values []float64) mstats.Percentiles {
+	sort.Float64s(values)
+	at := func(p float64) float64 {
+		i := int(p * float64(len(values)-1))
+		return values[i]
+	}
+	return mstats.Percentiles{
+		P10: at(0.1),
+		P25: at(0.25),
+		P50: at(0.5),
+		P75: at(0.75),
+		P90: at(0.9),
+	}
+}
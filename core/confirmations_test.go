@@ -0,0 +1,62 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+)
+
+// wait is counted in blocks from the height the tx was first seen at
+func TestConfirmationTimes(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+	now := time.Now()
+	c.confirmations.startedAt = now.Add(-time.Minute)
+
+	// 12 and 60 sat/vB, the old one was in the pool before the start
+	fast, slow, old := testTx(0, funding), testTx(1, funding), testTx(2, funding)
+	node.AddPoolTx(fast, txpool.TxPool{Fee: 2400, Vsize: 200, Time: now.Unix()})
+	node.AddPoolTx(slow, txpool.TxPool{Fee: 12_000, Vsize: 200, Time: now.Unix()})
+	node.AddPoolTx(old, txpool.TxPool{Fee: 12_000, Vsize: 200, Time: now.Add(-time.Hour).Unix()})
+	tick(c)
+
+	node.Mine(fast.Txid, old.Txid)
+	tick(c)
+	resolveRemovals(c)
+	node.Mine()
+	tick(c)
+	node.Mine(slow.Txid)
+	tick(c)
+	resolveRemovals(c)
+
+	tests := []struct {
+		name    string
+		band    uint
+		samples int
+		blocks  float64
+	}{
+		{"fast", 15, 1, 1},
+		{"slow", 70, 1, 3},
+	}
+	times := c.GetConfirmationTimes()
+	if len(times) != len(tests) {
+		t.Fatalf("confirmation times %+v, want %d bands", times, len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := times[i]
+			if got.FeeRate != tt.band || got.Samples != tt.samples {
+				t.Fatalf("band %d with %d samples, want %d with %d", got.FeeRate, got.Samples, tt.band, tt.samples)
+			}
+			if got.Blocks.P10 != tt.blocks || got.Blocks.P90 != tt.blocks {
+				t.Fatalf("blocks %+v, want %v", got.Blocks, tt.blocks)
+			}
+			if got.Seconds.P50 < 0 {
+				t.Fatalf("seconds %+v", got.Seconds)
+			}
+		})
+	}
+}
@@ -8,14 +8,21 @@ import (
 	"github.com/1F47E/go-feesh/client"
 	"github.com/1F47E/go-feesh/config"
 	"github.com/1F47E/go-feesh/logger"
+	"github.com/1F47E/go-feesh/miners"
 	"github.com/1F47E/go-feesh/notificator"
+	"github.com/1F47E/go-feesh/p2p"
 	"github.com/1F47E/go-feesh/storage"
 
 	"sync"
 
+	"github.com/1F47E/go-feesh/entity/btc/block"
 	"github.com/1F47E/go-feesh/entity/btc/info"
 	"github.com/1F47E/go-feesh/entity/btc/txpool"
 	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	mfee "github.com/1F47E/go-feesh/entity/models/fee"
+	mrbf "github.com/1F47E/go-feesh/entity/models/rbf"
+	mremoval "github.com/1F47E/go-feesh/entity/models/removal"
+	mstats "github.com/1F47E/go-feesh/entity/models/stats"
 	mtx "github.com/1F47E/go-feesh/entity/models/tx"
 )
 
@@ -23,10 +30,11 @@ type Core struct {
 	ctx     context.Context
 	mu      *sync.Mutex
 	Cfg     *config.Config
-	cli     *client.Client
+	cli     client.Node
 	storage storage.PoolRepository
 	// ws
 	broadcastCh chan notificator.Msg
+	eventsCh    chan notificator.Event
 
 	height int
 
@@ -40,21 +48,42 @@ type Core struct {
 
 	feeBucketsMap map[uint]uint
 	feeBuckets    []uint
+	feeEstimator  *FeeEstimator
+	rbf           *rbfTracker
+	removals      *removalTracker
+	confirmations *confirmationStats
+	prevouts      *prevoutCache
+	miners        *miners.Miners
 
-	poolCopy        []txpool.TxPool
 	poolCopyMap     map[string]txpool.TxPool
 	poolSorted      []mtx.Tx
 	poolSizeHistory []uint
+	projectedBlocks []mblock.Projected
+	templates       map[int]blockTemplate // tip height -> next block template
+	health          []mblock.Health
 
-	blockDepth  int      // how deep to scan the blocks from the top
-	blocksIndex []string // keep track of parsed blocks
-	blocks      []mblock.Block
+	blockDepth   int      // how deep to scan the blocks from the top
+	bestHash     string   // tip of the last blocks pull
+	blocksIndex  []string // keep track of parsed blocks
+	blocks       []mblock.Block
+	hashByHeight map[int]string // parsed blocks, to detect reorgs
+	blockHeaders map[string]*block.Block
+	backfill     *mblock.Backfill
 
 	// blocks      []*mblock.Block
-	parserJobCh chan string
+	parserJobCh  chan string
+	poolPullCh   chan struct{} // pull the pool now, pushed by the node
+	blocksPullCh chan struct{} // check the blocks now, pushed by the node
+	pushSources  int32         // connected push sources, atomic
+	pushCh       chan pushedTx // pushed pool changes, off the read loops
+	p2p          *p2p.Listener
 }
 
-func NewCore(ctx context.Context, cfg *config.Config, cli *client.Client, s storage.PoolRepository, broadcastCh chan notificator.Msg) *Core {
+func NewCore(ctx context.Context, cfg *config.Config, cli client.Node, s storage.PoolRepository, broadcastCh chan notificator.Msg, eventsCh chan notificator.Event) *Core {
+	m, err := miners.New(cfg.PoolsFile)
+	if err != nil {
+		logger.Log.Fatalf("error on loading mining pools: %v", err)
+	}
 	return &Core{
 		ctx:         ctx,
 		mu:          &sync.Mutex{},
@@ -62,16 +91,30 @@ func NewCore(ctx context.Context, cfg *config.Config, cli *client.Client, s stor
 		cli:         cli,
 		storage:     s,
 		broadcastCh: broadcastCh,
+		eventsCh:    eventsCh,
 
-		poolCopy:        make([]txpool.TxPool, 0),
 		poolCopyMap:     make(map[string]txpool.TxPool),
 		poolSorted:      make([]mtx.Tx, 0),
 		poolSizeHistory: make([]uint, 0),
+		projectedBlocks: make([]mblock.Projected, 0),
+		templates:       make(map[int]blockTemplate),
+		health:          make([]mblock.Health, 0),
+		feeEstimator:    NewFeeEstimator(),
+		rbf:             newRbfTracker(),
+		removals:        newRemovalTracker(),
+		confirmations:   newConfirmationStats(),
+		prevouts:        newPrevoutCache(cli, cfg.PrevoutCacheSize, cfg.RpcBatchSize),
+		miners:          m,
 		// blocks:      make([]*mblock.Block, 0),
-		blockDepth:  cfg.BlocksParsingDepth,
-		blocksIndex: make([]string, 0),
+		blockDepth:   cfg.BlocksParsingDepth,
+		blocksIndex:  make([]string, 0),
+		hashByHeight: make(map[int]string),
+		blockHeaders: make(map[string]*block.Block),
 		// block:       make(map[string]string),
-		parserJobCh: make(chan string),
+		parserJobCh:  make(chan string),
+		poolPullCh:   make(chan struct{}, 1),
+		blocksPullCh: make(chan struct{}, 1),
+		pushCh:       make(chan pushedTx, pushQueueSize),
 	}
 }
 
@@ -82,20 +125,22 @@ func (c *Core) Start() {
 	}
 	// TODO: move best block to worker
 	// set the pool block height
-	info, err := c.cli.GetInfo()
+	info, err := c.cli.GetInfo(c.ctx)
 	if err != nil {
 		log.Errorf("error on getinfo: %v\n", err)
 	} else {
 		// even if its fails - having block 0 will update pool txs list every time
 		// its just for performance reasons
-		c.height = info.Blocks
+		c.setHeight(info.Blocks)
 	}
 
+	go c.workerBackends(10 * time.Second)
 	go c.workerParserBlocks(3 * time.Second)
+	go c.bootstrap()
 	go c.workerBlocksProcessor(1 * time.Second)
 
 	// make a batch of parsers
-	// each parse makes a new RPC connection on every job
+	// each parser fetches the txs in batches over keep-alive connections
 	for i := 0; i < c.Cfg.RpcLimit; i++ {
 		go c.workerTxParser(i + 1)
 	}
@@ -104,23 +149,30 @@ func (c *Core) Start() {
 		go c.workerPoolDebug(1 * time.Second)
 		return
 	}
+	// pushed updates, polling is for the reconciliation while connected
+	go c.workerPush()
+	if c.Cfg.ZmqAddress != "" {
+		go c.workerZmq()
+	}
+	if c.Cfg.WsHost != "" {
+		go c.workerWs()
+	}
+	if len(c.Cfg.P2PPeers) > 0 {
+		go c.workerP2P()
+	}
 	go c.workerPoolPuller(1 * time.Second)
 	go c.workerPoolSorter(1 * time.Second)
 	go c.workerPoolSizeHistory(5 * time.Minute)
+	go c.workerRemovals(5 * time.Second)
 }
 
-func (c *Core) GetNodeInfo() (*info.Info, error) {
-	return c.cli.GetInfo()
+func (c *Core) GetNodeInfo(ctx context.Context) (*info.Info, error) {
+	return c.cli.GetInfo(ctx)
 }
 
-// parse last N blocks
-//
-//nolint:unused
-func (c *Core) bootstrap() {
-	// TODO: bootstap blocks
-	// get best block
-	// download header, get prev block, repeat N times
-	// parse every block
+// RPC backends health, the active one is used for all the calls
+func (c *Core) GetBackends() []client.BackendStatus {
+	return c.cli.Backends()
 }
 
 func (c *Core) GetPool(limit int) ([]mtx.Tx, error) {
@@ -131,9 +183,18 @@ func (c *Core) GetPool(limit int) ([]mtx.Tx, error) {
 }
 
 func (c *Core) GetHeight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.height
 }
 
+// height is updated by the pollers and read by the push sources
+func (c *Core) setHeight(height int) {
+	c.mu.Lock()
+	c.height = height
+	c.mu.Unlock()
+}
+
 func (c *Core) GetPoolSize() int {
 	return len(c.poolSorted)
 }
@@ -162,6 +223,39 @@ func (c *Core) GetFeeBuckets() []uint {
 	return c.feeBuckets
 }
 
+func (c *Core) GetFeeRecommended() mfee.Recommended {
+	return c.feeEstimator.Get()
+}
+
+// recent replacements, new first
+func (c *Core) GetReplacements(limit int) []mrbf.Replacement {
+	return c.rbf.History(limit)
+}
+
+// replacement chain of the tx, empty if it was never replaced
+func (c *Core) GetTxReplacements(txid string) []mrbf.Replacement {
+	return c.rbf.Chain(txid)
+}
+
+// removed txs count by reason
+func (c *Core) GetRemovalCounters() map[mremoval.Reason]uint64 {
+	return c.removals.Counters()
+}
+
+// recent removals, new first
+func (c *Core) GetRemovals(limit int) []mremoval.Removal {
+	return c.removals.Recent(limit)
+}
+
+func (c *Core) GetTxRemoval(txid string) (mremoval.Removal, bool) {
+	return c.removals.Get(txid)
+}
+
+// confirmation wait percentiles per fee band
+func (c *Core) GetConfirmationTimes() []mstats.ConfirmationTimes {
+	return c.confirmations.Get()
+}
+
 func (c *Core) GetTotalSize() uint64 {
 	sizeBytes := c.totalSize
 	sizeKb := sizeBytes / 1024
@@ -170,5 +264,28 @@ func (c *Core) GetTotalSize() uint64 {
 }
 
 func (c *Core) GetBlocks() []mblock.Block {
-	return c.blocks
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	blocks := make([]mblock.Block, len(c.blocks))
+	copy(blocks, c.blocks)
+	return blocks
+}
+
+// block stats from mem or storage, nil if not found
+func (c *Core) GetBlock(hash string) (*mblock.Block, error) {
+	c.mu.Lock()
+	for _, b := range c.blocks {
+		if b.Hash == hash {
+			c.mu.Unlock()
+			return &b, nil
+		}
+	}
+	c.mu.Unlock()
+	return c.storage.BlockStatsGet(hash)
+}
+
+func (c *Core) GetProjectedBlocks() []mblock.Projected {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.projectedBlocks
 }
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/config"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	"github.com/1F47E/go-feesh/logger"
+	"github.com/1F47E/go-feesh/notificator"
+	"github.com/1F47E/go-feesh/storage"
+	smap "github.com/1F47E/go-feesh/storage/map"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DEBUG") != "1" {
+		logger.Log.SetLevel(logrus.ErrorLevel)
+	}
+	os.Exit(m.Run())
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		RpcLimit:           2,
+		RpcBatchSize:       10,
+		BlocksParsingDepth: 3,
+		PrevoutCacheSize:   1000,
+	}
+}
+
+// core over the fake node and the map storage, ws messages are drained
+func newTestCore(t *testing.T, cfg *config.Config, node client.Node) *Core {
+	t.Helper()
+	return newTestCoreOn(t, cfg, node, smap.New())
+}
+
+// same over the given storage, to restart the core with the stored data
+func newTestCoreOn(t *testing.T, cfg *config.Config, node client.Node, s storage.PoolRepository) *Core {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	c := NewCore(ctx, cfg, node, s, make(chan notificator.Msg), make(chan notificator.Event))
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.broadcastCh:
+			case <-c.eventsCh:
+			}
+		}
+	}()
+	return c
+}
+
+// confirmed tx with n outputs for the test txs to spend, 0.01 each.
+// spends the coinbase, so the block stats are complete
+func testFunding(node *client.FakeNode, n int) string {
+	coinbase := node.Mine().Transactions[0]
+	funding := &tx.Transaction{
+		Txid: fmt.Sprintf("%064x", 999_999),
+		Vin:  []tx.Vin{{Txid: coinbase, Vout: 0}},
+	}
+	for i := 0; i < n; i++ {
+		funding.Vout = append(funding.Vout, tx.Vout{Value: 0.01, N: i})
+	}
+	node.AddTx(funding)
+	node.Mine(funding.Txid)
+	return funding.Txid
+}
+
+// pool tx spending the n-th output of the funding tx
+func testTx(n int, funding string) *tx.Transaction {
+	return &tx.Transaction{
+		Txid:   fmt.Sprintf("%064x", 1_000_000+n),
+		Size:   200,
+		Weight: 800,
+		Vin:    []tx.Vin{{Txid: funding, Vout: n}},
+		Vout:   []tx.Vout{{Value: 0.001, N: 0}},
+	}
+}
+
+// n pool txs spending the funding outputs from the given one, fee rate in sat/vB
+func addPoolTxs(node *client.FakeNode, funding string, from, n, weight int, rate uint64) {
+	for i := from; i < from+n; i++ {
+		t := testTx(i, funding)
+		t.Weight = weight
+		t.Size = weight / 4
+		node.AddPoolTx(t, txpool.TxPool{Fee: rate * uint64(weight/4), Vsize: uint32(weight / 4)})
+	}
+}
+
+// one tick of the pool and block workers, in the order they usually see the changes
+func tick(c *Core) {
+	txids, inPool := c.pullPool()
+	if len(txids) > 0 {
+		c.parseBatch(txids)
+	}
+	if inPool != nil {
+		c.rbf.Prune(inPool)
+	}
+	if txids := c.pullBlocks(); len(txids) > 0 {
+		c.parseBatch(txids)
+	}
+	c.processBlocks()
+	c.sortPool()
+}
+
+// apply the next scripted node step and tick the workers
+func step(t *testing.T, node *client.FakeNode, c *Core) {
+	t.Helper()
+	if !node.Next() {
+		t.Fatal("no more scripted steps")
+	}
+	tick(c)
+}
+
+func poolTxids(c *Core) map[string]bool {
+	pool, _ := c.GetPool(1000)
+	ret := make(map[string]bool, len(pool))
+	for _, tx := range pool {
+		ret[tx.Hash] = true
+	}
+	return ret
+}
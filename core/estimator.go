@@ -0,0 +1,118 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/1F47E/go-feesh/config"
+	mfee "github.com/1F47E/go-feesh/entity/models/fee"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+// confirmation targets in blocks
+const (
+	feeTargetNextBlock = 1
+	feeTargetHalfHour  = 3
+	feeTargetHour      = 6
+	feeTargetEconomy   = 24 // around 4 hours
+)
+
+// min relay fee, sat/vB
+const feeMinimum = 1
+
+// FeeEstimator projects the pool snapshot into blocks ordered by fee rate
+// and takes the lowest rate that still makes it into the target block
+type FeeEstimator struct {
+	mu         *sync.Mutex
+	blockVsize uint64
+	last       mfee.Recommended
+}
+
+func NewFeeEstimator() *FeeEstimator {
+	return &FeeEstimator{
+		mu:         &sync.Mutex{},
+		blockVsize: config.BLOCK_SIZE / 4,
+		last:       minimumFees(),
+	}
+}
+
+// calc recommended fees from the pool snapshot and keep them as the last result
+func (e *FeeEstimator) Update(txs []mtx.Tx) mfee.Recommended {
+	type rateTx struct {
+		rate  float64
+		vsize uint64
+	}
+	rates := make([]rateTx, 0, len(txs))
+	for i := range txs {
+		// fee is unknown for the tx not yet matched with the pool
+		if txs[i].Fee == 0 {
+			continue
+		}
+		rates = append(rates, rateTx{txs[i].FeeRate(), uint64(txs[i].VSize())})
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].rate > rates[j].rate
+	})
+
+	// fill the blocks one by one, remember the lowest rate of every full block
+	blockMins := make([]float64, 0, feeTargetEconomy)
+	var blockSize uint64
+	blockMin := math.MaxFloat64
+	for _, r := range rates {
+		if blockSize+r.vsize > e.blockVsize {
+			blockMins = append(blockMins, blockMin)
+			if len(blockMins) == feeTargetEconomy {
+				break
+			}
+			blockSize = 0
+			blockMin = math.MaxFloat64
+		}
+		blockSize += r.vsize
+		if r.rate < blockMin {
+			blockMin = r.rate
+		}
+	}
+
+	rec := mfee.Recommended{
+		NextBlock: feeForTarget(blockMins, feeTargetNextBlock),
+		HalfHour:  feeForTarget(blockMins, feeTargetHalfHour),
+		Hour:      feeForTarget(blockMins, feeTargetHour),
+		Economy:   feeForTarget(blockMins, feeTargetEconomy),
+		Minimum:   feeMinimum,
+	}
+
+	e.mu.Lock()
+	e.last = rec
+	e.mu.Unlock()
+	return rec
+}
+
+// last calculated fees
+func (e *FeeEstimator) Get() mfee.Recommended {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.last
+}
+
+// if the pool does not fill the target block, any fee above minimum will do
+func feeForTarget(blockMins []float64, target int) uint {
+	if len(blockMins) < target {
+		return feeMinimum
+	}
+	fee := uint(math.Ceil(blockMins[target-1]))
+	if fee < feeMinimum {
+		return feeMinimum
+	}
+	return fee
+}
+
+func minimumFees() mfee.Recommended {
+	return mfee.Recommended{
+		NextBlock: feeMinimum,
+		HalfHour:  feeMinimum,
+		Hour:      feeMinimum,
+		Economy:   feeMinimum,
+		Minimum:   feeMinimum,
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+	mfee "github.com/1F47E/go-feesh/entity/models/fee"
+)
+
+func TestFeeEstimatorTargets(t *testing.T) {
+	// 10k vB txs, 100 of them fill the block
+	type band struct {
+		n    int
+		rate uint64
+	}
+	tests := []struct {
+		name  string
+		bands []band
+		want  mfee.Recommended
+	}{
+		{"empty pool", nil, minimumFees()},
+		{"partial block", []band{{50, 30}}, minimumFees()},
+		{
+			"two and a half blocks",
+			[]band{{100, 50}, {100, 20}, {50, 5}},
+			mfee.Recommended{NextBlock: 50, HalfHour: 1, Hour: 1, Economy: 1, Minimum: 1},
+		},
+		{
+			"six and a half blocks",
+			[]band{{100, 50}, {100, 40}, {100, 30}, {100, 20}, {100, 10}, {100, 8}, {50, 2}},
+			mfee.Recommended{NextBlock: 50, HalfHour: 30, Hour: 8, Economy: 1, Minimum: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := client.NewFakeNode()
+			funding := testFunding(node, 1000)
+			cfg := testConfig()
+			cfg.RpcBatchSize = 100
+			c := newTestCore(t, cfg, node)
+
+			from := 0
+			for _, b := range tt.bands {
+				addPoolTxs(node, funding, from, b.n, 40_000, b.rate)
+				from += b.n
+			}
+			tick(c)
+			if n := c.GetPoolSize(); n != from {
+				t.Fatalf("pool %d, want %d", n, from)
+			}
+			if got := c.GetFeeRecommended(); got != tt.want {
+				t.Fatalf("recommended %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
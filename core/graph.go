@@ -0,0 +1,110 @@
+package core
+
+import (
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+// in-pool dependency graph. parent is a pool tx whose output is spent by the child
+type txGraph struct {
+	parents  map[string][]string
+	children map[string][]string
+}
+
+func newTxGraph(txs []mtx.Tx) *txGraph {
+	inPool := make(map[string]bool, len(txs))
+	for i := range txs {
+		inPool[txs[i].Hash] = true
+	}
+	g := &txGraph{
+		parents:  make(map[string][]string),
+		children: make(map[string][]string),
+	}
+	for i := range txs {
+		for _, ptxid := range txs[i].Depends {
+			if !inPool[ptxid] {
+				continue
+			}
+			g.parents[txs[i].Hash] = append(g.parents[txs[i].Hash], ptxid)
+			g.children[ptxid] = append(g.children[ptxid], txs[i].Hash)
+		}
+	}
+	return g
+}
+
+// all txs reachable via the links, not including the tx itself
+func (g *txGraph) walk(txid string, links map[string][]string) []string {
+	res := make([]string, 0)
+	seen := map[string]bool{txid: true}
+	stack := []string{txid}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, next := range links[cur] {
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			res = append(res, next)
+			stack = append(stack, next)
+		}
+	}
+	return res
+}
+
+func (g *txGraph) ancestors(txid string) []string {
+	return g.walk(txid, g.parents)
+}
+
+func (g *txGraph) descendants(txid string) []string {
+	return g.walk(txid, g.children)
+}
+
+// fill ancestor and descendant stats for every tx.
+// effective fee rate is the package rate the tx is projected to be mined with,
+// for the txs beyond projected blocks its the best of the own ancestor package
+// and the descendant packages paying for it
+func (g *txGraph) applyPackages(txs []mtx.Tx, projectedRates map[string]float64) {
+	index := make(map[string]*mtx.Tx, len(txs))
+	for i := range txs {
+		index[txs[i].Hash] = &txs[i]
+	}
+	ancRate := func(t *mtx.Tx) float64 {
+		return float64(t.AncestorFees) / float64(t.AncestorSize)
+	}
+
+	for i := range txs {
+		t := &txs[i]
+		t.AncestorCount, t.AncestorSize, t.AncestorFees = 1, uint64(t.VSize()), t.Fee
+		for _, txid := range g.ancestors(t.Hash) {
+			a := index[txid]
+			t.AncestorCount++
+			t.AncestorSize += uint64(a.VSize())
+			t.AncestorFees += a.Fee
+		}
+		t.DescendantCount, t.DescendantSize, t.DescendantFees = 1, uint64(t.VSize()), t.Fee
+		for _, txid := range g.descendants(t.Hash) {
+			d := index[txid]
+			t.DescendantCount++
+			t.DescendantSize += uint64(d.VSize())
+			t.DescendantFees += d.Fee
+		}
+	}
+
+	for i := range txs {
+		t := &txs[i]
+		if rate, ok := projectedRates[t.Hash]; ok {
+			t.EffectiveFeeRate = rate
+			continue
+		}
+		if t.AncestorSize == 0 {
+			continue
+		}
+		rate := ancRate(t)
+		for _, txid := range g.descendants(t.Hash) {
+			if r := ancRate(index[txid]); r > rate {
+				rate = r
+			}
+		}
+		t.EffectiveFeeRate = rate
+	}
+}
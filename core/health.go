@@ -0,0 +1,119 @@
+package core
+
+import (
+	"time"
+
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// templates of the last heights to keep
+const templatesKeep = 3
+
+var healthLogLimit = 100
+
+// first projected block at the moment of the tip height
+type blockTemplate struct {
+	time  time.Time
+	txids []string
+}
+
+// caller holds c.mu
+func (c *Core) saveTemplate(height int, txids []string) {
+	c.templates[height] = blockTemplate{time: time.Now(), txids: txids}
+	for h := range c.templates {
+		if h <= height-templatesKeep {
+			delete(c.templates, h)
+		}
+	}
+}
+
+// compare the mined block against the last template built on the previous tip.
+// returns nil if there was no template, e.g. right after the start
+func (c *Core) blockHealth(hash string, height int, txids []string) *mblock.Health {
+	c.mu.Lock()
+	t, ok := c.templates[height-1]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	expected := make(map[string]bool, len(t.txids))
+	for _, txid := range t.txids {
+		expected[txid] = true
+	}
+	h := mblock.Health{
+		Hash:         hash,
+		Height:       height,
+		Expected:     len(t.txids),
+		Missing:      make([]string, 0),
+		Unexpected:   make([]string, 0),
+		TemplateTime: t.time,
+		Time:         time.Now(),
+	}
+	mined := make(map[string]bool, len(txids))
+	for i, txid := range txids {
+		// coinbase is never in the template
+		if i == 0 {
+			continue
+		}
+		mined[txid] = true
+		if expected[txid] {
+			h.Matched++
+		} else {
+			h.Unexpected = append(h.Unexpected, txid)
+		}
+	}
+	h.Mined = len(mined)
+	for _, txid := range t.txids {
+		if !mined[txid] {
+			h.Missing = append(h.Missing, txid)
+		}
+	}
+	if union := h.Expected + h.Mined - h.Matched; union > 0 {
+		h.Match = float64(h.Matched) / float64(union) * 100
+	}
+	return &h
+}
+
+func (c *Core) addHealth(h mblock.Health) {
+	c.mu.Lock()
+	c.health = append(c.health, h)
+	if len(c.health) > healthLogLimit {
+		c.health = c.health[len(c.health)-healthLogLimit:]
+	}
+	c.mu.Unlock()
+	if err := c.storage.BlockHealthAdd(h); err != nil {
+		logger.Log.Errorf("error on blockhealthadd: %v\n", err)
+	}
+}
+
+// health of the orphaned tip
+func (c *Core) dropHealth(hash string) {
+	c.mu.Lock()
+	health := make([]mblock.Health, 0, len(c.health))
+	for _, h := range c.health {
+		if h.Hash != hash {
+			health = append(health, h)
+		}
+	}
+	c.health = health
+	c.mu.Unlock()
+	if err := c.storage.BlockHealthDelete(hash); err != nil {
+		logger.Log.Errorf("error on blockhealthdelete: %v\n", err)
+	}
+}
+
+// recent blocks health, new first
+func (c *Core) GetBlocksHealth(limit int) []mblock.Health {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]mblock.Health, 0, limit)
+	for i := len(c.health) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, c.health[i])
+	}
+	return res
+}
+
+func (c *Core) GetBlockHealth(hash string) (*mblock.Health, error) {
+	return c.storage.BlockHealthGet(hash)
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+)
+
+// mined tip is compared with the template projected on the previous one
+func TestBlockHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		mined      []int // testTx numbers, 3 was never in the pool
+		matched    int
+		missing    []int
+		unexpected []int
+		match      float64
+	}{
+		{"as expected", []int{0, 1, 2}, 3, nil, nil, 100},
+		{"differs", []int{0, 2, 3}, 2, []int{1}, []int{3}, 50},
+		{"empty", nil, 0, []int{0, 1, 2}, nil, 0},
+	}
+	txids := func(funding string, ns []int) []string {
+		res := make([]string, 0, len(ns))
+		for _, n := range ns {
+			res = append(res, testTx(n, funding).Txid)
+		}
+		return res
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := client.NewFakeNode()
+			funding := testFunding(node, 10)
+			c := newTestCore(t, testConfig(), node)
+			// template is ordered by the fee rate
+			for i := 0; i < 3; i++ {
+				node.AddPoolTx(testTx(i, funding), txpool.TxPool{Fee: uint64(3000 - i*1000), Vsize: 200})
+			}
+			node.AddTx(testTx(3, funding))
+			tick(c)
+
+			b := node.Mine(txids(funding, tt.mined)...)
+			tick(c)
+			health := c.GetBlocksHealth(10)
+			if len(health) != 1 {
+				t.Fatalf("health %+v, want the mined tip", health)
+			}
+			h := health[0]
+			if h.Hash != b.Hash || h.Height != b.Height || h.Expected != 3 || h.Mined != len(tt.mined) {
+				t.Fatalf("health %+v", h)
+			}
+			if h.Matched != tt.matched || h.Match != tt.match {
+				t.Fatalf("matched %d, %v%%, want %d, %v%%", h.Matched, h.Match, tt.matched, tt.match)
+			}
+			if fmt.Sprint(h.Missing) != fmt.Sprint(txids(funding, tt.missing)) {
+				t.Fatalf("missing %v, want %v", h.Missing, txids(funding, tt.missing))
+			}
+			if fmt.Sprint(h.Unexpected) != fmt.Sprint(txids(funding, tt.unexpected)) {
+				t.Fatalf("unexpected %v, want %v", h.Unexpected, txids(funding, tt.unexpected))
+			}
+			stored, err := c.GetBlockHealth(b.Hash)
+			if err != nil || stored == nil || stored.Match != tt.match {
+				t.Fatalf("stored health %+v, %v", stored, err)
+			}
+		})
+	}
+}
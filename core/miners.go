@@ -0,0 +1,74 @@
+package core
+
+import (
+	"sort"
+
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	mstats "github.com/1F47E/go-feesh/entity/models/stats"
+)
+
+// per pool block share and fee revenue over the last window blocks
+func (c *Core) GetMinerStats(window int) (*mstats.Miners, error) {
+	to := c.GetHeight()
+	from := to - window + 1
+	if from < 0 {
+		from = 0
+	}
+	blocks, err := c.storage.BlockStatsRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	blocks = c.mainChainStats(blocks)
+	byPool := make(map[string]*mstats.Miner)
+	for _, b := range blocks {
+		pool := b.Pool
+		if pool == "" {
+			pool = "unknown"
+		}
+		m, ok := byPool[pool]
+		if !ok {
+			m = &mstats.Miner{Pool: pool}
+			byPool[pool] = m
+		}
+		m.Blocks++
+		m.Fee += b.Fee
+		m.Reward += b.Reward
+	}
+	res := &mstats.Miners{
+		Window:     window,
+		FromHeight: from,
+		ToHeight:   to,
+		Blocks:     len(blocks),
+		Pools:      make([]mstats.Miner, 0, len(byPool)),
+	}
+	for _, m := range byPool {
+		m.Share = float64(m.Blocks) / float64(len(blocks))
+		res.Pools = append(res.Pools, *m)
+	}
+	sort.Slice(res.Pools, func(i, j int) bool {
+		if res.Pools[i].Blocks != res.Pools[j].Blocks {
+			return res.Pools[i].Blocks > res.Pools[j].Blocks
+		}
+		return res.Pools[i].Pool < res.Pools[j].Pool
+	})
+	return res, nil
+}
+
+// one block per height. stats of the orphaned blocks the rollback missed
+// (reorg while stopped, deeper than the parsed blocks) are deleted by the block workers and the backfill.
+// till then the parsed block of the height is counted, the height is skipped if there is none
+func (c *Core) mainChainStats(blocks []mblock.Block) []mblock.Block {
+	perHeight := make(map[int]int)
+	for _, b := range blocks {
+		perHeight[b.Height]++
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]mblock.Block, 0, len(perHeight))
+	for _, b := range blocks {
+		if perHeight[b.Height] == 1 || c.hashByHeight[b.Height] == b.Hash {
+			res = append(res, b)
+		}
+	}
+	return res
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+)
+
+// orphaned stats left in storage are not counted twice, the block workers delete them
+func TestMinerStatsAfterReorg(t *testing.T) {
+	node := client.NewFakeNode()
+	testFunding(node, 1)
+	node.Mine()
+	cfg := testConfig()
+	cfg.BlocksParsingDepth = 2
+	c := newTestCore(t, cfg, node)
+	tick(c)
+	if len(c.GetBlocks()) != 2 {
+		t.Fatalf("blocks %d, want 2", len(c.GetBlocks()))
+	}
+	// parsed before, out of the window now
+	hash, _ := node.GetBlockHash(c.ctx, 1)
+	_ = c.storage.BlockStatsAdd(mblock.Block{Hash: hash, Height: 1, Pool: "main"})
+
+	// stale stats at the parsed height and below the parsed window
+	tip := c.GetHeight()
+	for _, height := range []int{tip, 1} {
+		stale := mblock.Block{Hash: fmt.Sprintf("stale%d", height), Height: height, Pool: "stale"}
+		_ = c.storage.BlockStatsAdd(stale)
+	}
+	calls := node.Calls("getblockhash")
+	stats, err := c.GetMinerStats(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// height 1 is ambiguous till it is cleaned up
+	if stats.Blocks != tip-1 {
+		t.Fatalf("blocks %d, want %d", stats.Blocks, tip-1)
+	}
+	for _, m := range stats.Pools {
+		if m.Pool == "stale" || m.Pool == "main" {
+			t.Fatalf("ambiguous or orphaned blocks counted: %+v", m)
+		}
+	}
+	// read only
+	if n := node.Calls("getblockhash"); n != calls {
+		t.Fatalf("getblockhash called %d times by the stats", n-calls)
+	}
+	if got := countPool(t, c, "stale"); got != 2 {
+		t.Fatalf("stale stats %d, want 2 before the cleanup", got)
+	}
+
+	// next block pull cleans up the window, backfill the older heights
+	node.Mine()
+	tick(c)
+	if got, _ := c.storage.BlockStatsGet(fmt.Sprintf("stale%d", tip)); got != nil {
+		t.Fatalf("orphaned stats in the window not deleted: %+v", got)
+	}
+	backfillWithTicks(t, c, 1, 0)
+	if got := countPool(t, c, "stale"); got != 0 {
+		t.Fatalf("stale stats %d after the cleanup", got)
+	}
+	stats, _ = c.GetMinerStats(10)
+	if stats.Blocks != c.GetHeight() {
+		t.Fatalf("blocks %d, want %d", stats.Blocks, c.GetHeight())
+	}
+}
+
+func countPool(t *testing.T, c *Core, pool string) int {
+	t.Helper()
+	res, err := c.storage.BlockStatsRange(0, c.GetHeight())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, b := range res {
+		if b.Pool == pool {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+)
+
+type prevoutEntry struct {
+	txid   string
+	values []uint64
+}
+
+// prevoutCache is LRU of tx out amounts by txid.
+// block txs often spend outputs of the recent txs, so most of the inputs are resolved without RPC
+type prevoutCache struct {
+	mu        *sync.Mutex
+	cli       client.Node
+	size      int
+	batchSize int
+	ll        *list.List
+	items     map[string]*list.Element
+}
+
+func newPrevoutCache(cli client.Node, size, batchSize int) *prevoutCache {
+	return &prevoutCache{
+		mu:        &sync.Mutex{},
+		cli:       cli,
+		size:      size,
+		batchSize: batchSize,
+		ll:        list.New(),
+		items:     make(map[string]*list.Element),
+	}
+}
+
+func (p *prevoutCache) Get(txid string) ([]uint64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	el, ok := p.items[txid]
+	if !ok {
+		return nil, false
+	}
+	p.ll.MoveToFront(el)
+	return el.Value.(*prevoutEntry).values, true
+}
+
+func (p *prevoutCache) Add(txid string, values []uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.items[txid]; ok {
+		p.ll.MoveToFront(el)
+		el.Value.(*prevoutEntry).values = values
+		return
+	}
+	p.items[txid] = p.ll.PushFront(&prevoutEntry{txid, values})
+	for p.ll.Len() > p.size {
+		last := p.ll.Back()
+		p.ll.Remove(last)
+		delete(p.items, last.Value.(*prevoutEntry).txid)
+	}
+}
+
+// out amounts of the tx, fetched from the node on cache miss.
+// confirmed txs require node with tx index
+func (p *prevoutCache) values(ctx context.Context, txid string) ([]uint64, error) {
+	if values, ok := p.Get(txid); ok {
+		return values, nil
+	}
+	ptx, err := p.cli.TransactionGet(ctx, txid)
+	if err != nil {
+		return nil, err
+	}
+	values := ptx.GetOutValues()
+	p.Add(txid, values)
+	return values, nil
+}
+
+// fetch the missing prevouts of the txs with batch requests.
+// the ones that failed are fetched again one by one in AmountIn
+func (p *prevoutCache) Prefetch(ctx context.Context, txs []*tx.Transaction) error {
+	seen := make(map[string]bool)
+	missing := make([]string, 0)
+	for _, t := range txs {
+		for _, vin := range t.Vin {
+			if vin.Coinbase != "" || seen[vin.Txid] {
+				continue
+			}
+			seen[vin.Txid] = true
+			if _, ok := p.Get(vin.Txid); !ok {
+				missing = append(missing, vin.Txid)
+			}
+		}
+	}
+	for len(missing) > 0 {
+		n := p.batchSize
+		if n < 1 || n > len(missing) {
+			n = len(missing)
+		}
+		ptxs, _, err := p.cli.TransactionGetMany(ctx, missing[:n])
+		if err != nil {
+			return err
+		}
+		for txid, ptx := range ptxs {
+			p.Add(txid, ptx.GetOutValues())
+		}
+		missing = missing[n:]
+	}
+	return nil
+}
+
+// total amount of the tx inputs in sats
+func (p *prevoutCache) AmountIn(ctx context.Context, t *tx.Transaction) (uint64, error) {
+	var in uint64
+	for _, vin := range t.Vin {
+		if vin.Coinbase != "" {
+			continue
+		}
+		values, err := p.values(ctx, vin.Txid)
+		if err != nil {
+			return 0, fmt.Errorf("prevout %s: %w", vin.Txid, err)
+		}
+		if vin.Vout < 0 || vin.Vout >= len(values) {
+			return 0, fmt.Errorf("prevout %s has no output %d", vin.Txid, vin.Vout)
+		}
+		in += values[vin.Vout]
+	}
+	return in, nil
+}
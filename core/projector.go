@@ -0,0 +1,209 @@
+package core
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/1F47E/go-feesh/config"
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+const projectedBlocksCount = 8
+
+// how many packages can fail to fit before the block is considered full
+const projectorMaxFailures = 1000
+
+type projEntry struct {
+	tx       *mtx.Tx
+	weight   uint64
+	parents  []*projEntry
+	children []*projEntry
+	selected bool
+}
+
+// package is the entry itself with all the not yet selected ancestors
+// returned in the order they can be mined, parents first
+func (e *projEntry) pkg() []*projEntry {
+	res := make([]*projEntry, 0, 1)
+	seen := make(map[*projEntry]bool)
+	var walk func(p *projEntry)
+	walk = func(p *projEntry) {
+		if p.selected || seen[p] {
+			return
+		}
+		seen[p] = true
+		for _, parent := range p.parents {
+			walk(parent)
+		}
+		res = append(res, p)
+	}
+	walk(e)
+	return res
+}
+
+func pkgTotals(pkg []*projEntry) (fee, weight uint64) {
+	for _, p := range pkg {
+		fee += p.tx.Fee
+		weight += p.weight
+	}
+	return fee, weight
+}
+
+// sat/vB
+func feeRate(fee, weight uint64) float64 {
+	if weight == 0 {
+		return 0
+	}
+	return float64(fee) / (float64(weight) / 4)
+}
+
+type projItem struct {
+	entry *projEntry
+	score float64
+}
+
+// max heap by ancestor score
+type projHeap []projItem
+
+func (h projHeap) Len() int            { return len(h) }
+func (h projHeap) Less(i, j int) bool  { return h[i].score > h[j].score }
+func (h projHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *projHeap) Push(x interface{}) { *h = append(*h, x.(projItem)) }
+func (h *projHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	*h = old[:n-1]
+	return it
+}
+
+type projBlock struct {
+	weight uint64
+	fee    uint64
+	txids  []string
+	rates  []float64
+}
+
+func (b *projBlock) result(index int) mblock.Projected {
+	ret := mblock.Projected{
+		Index:  index,
+		Txs:    len(b.txids),
+		VSize:  (b.weight + 3) / 4,
+		Weight: b.weight,
+		Fee:    b.fee,
+		Txids:  b.txids,
+	}
+	if len(b.rates) > 0 {
+		rates := make([]float64, len(b.rates))
+		copy(rates, b.rates)
+		sort.Float64s(rates)
+		ret.FeeRateMin = rates[0]
+		ret.FeeRateMedian = rates[len(rates)/2]
+		ret.FeeRateMax = rates[len(rates)-1]
+	}
+	return ret
+}
+
+// projectBlocks greedily builds next block templates the way miners do:
+// packages (tx with unconfirmed ancestors) are taken by ancestor fee rate
+// until the block weight limit is reached.
+// depends maps txid to its in-pool parents, can be nil.
+// also returns the package fee rate every projected tx was taken with
+func projectBlocks(txs []mtx.Tx, depends map[string][]string, count int) ([]mblock.Projected, map[string]float64) {
+	entries := make(map[string]*projEntry, len(txs))
+	for i := range txs {
+		w := uint64(txs[i].Weight)
+		if w == 0 {
+			w = uint64(txs[i].Size) * 4
+		}
+		entries[txs[i].Hash] = &projEntry{tx: &txs[i], weight: w}
+	}
+	for txid, parents := range depends {
+		e, ok := entries[txid]
+		if !ok {
+			continue
+		}
+		for _, ptxid := range parents {
+			p, ok := entries[ptxid]
+			if !ok {
+				continue
+			}
+			e.parents = append(e.parents, p)
+			p.children = append(p.children, e)
+		}
+	}
+
+	h := make(projHeap, 0, len(entries))
+	for _, e := range entries {
+		fee, weight := pkgTotals(e.pkg())
+		h = append(h, projItem{e, feeRate(fee, weight)})
+	}
+	heap.Init(&h)
+
+	res := make([]mblock.Projected, 0, count)
+	rates := make(map[string]float64)
+	cur := &projBlock{}
+	deferred := make([]projItem, 0)
+	failures := 0
+	closeBlock := func() {
+		res = append(res, cur.result(len(res)))
+		cur = &projBlock{}
+		for _, it := range deferred {
+			heap.Push(&h, it)
+		}
+		deferred = deferred[:0]
+		failures = 0
+	}
+
+	for len(res) < count {
+		if h.Len() == 0 {
+			if len(cur.txids) > 0 {
+				closeBlock()
+				continue
+			}
+			break
+		}
+		it := heap.Pop(&h).(projItem)
+		if it.entry.selected {
+			continue
+		}
+		pkg := it.entry.pkg()
+		fee, weight := pkgTotals(pkg)
+		score := feeRate(fee, weight)
+		// ancestors were mined since the score was calculated
+		if score != it.score {
+			heap.Push(&h, projItem{it.entry, score})
+			continue
+		}
+		// oversized package goes to the empty block anyway
+		if cur.weight+weight > config.BLOCK_SIZE && len(cur.txids) > 0 {
+			deferred = append(deferred, it)
+			failures++
+			if failures > projectorMaxFailures || cur.weight+4000 > config.BLOCK_SIZE {
+				closeBlock()
+			}
+			continue
+		}
+		for _, p := range pkg {
+			p.selected = true
+			cur.weight += p.weight
+			cur.fee += p.tx.Fee
+			cur.txids = append(cur.txids, p.tx.Hash)
+			cur.rates = append(cur.rates, score)
+			rates[p.tx.Hash] = score
+		}
+		failures = 0
+		// descendants score changed, queue them again
+		for _, p := range pkg {
+			for _, child := range p.children {
+				if child.selected {
+					continue
+				}
+				cfee, cweight := pkgTotals(child.pkg())
+				heap.Push(&h, projItem{child, feeRate(cfee, cweight)})
+			}
+		}
+	}
+	return res, rates
+}
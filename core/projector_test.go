@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/config"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+func poolByTxid(c *Core) map[string]mtx.Tx {
+	pool, _ := c.GetPool(c.GetPoolSize())
+	ret := make(map[string]mtx.Tx, len(pool))
+	for _, t := range pool {
+		ret[t.Hash] = t
+	}
+	return ret
+}
+
+// low fee parent is mined with the high fee child as a package
+func TestProjectorCPFP(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+
+	// 1 sat/vB parent, 100 sat/vB child, 20 sat/vB in between
+	parent, mid := testTx(0, funding), testTx(1, funding)
+	child := &tx.Transaction{
+		Txid:   fmt.Sprintf("%064x", 2_000_000),
+		Size:   200,
+		Weight: 800,
+		Vin:    []tx.Vin{{Txid: parent.Txid, Vout: 0}},
+		Vout:   []tx.Vout{{Value: 0.0009, N: 0}},
+	}
+	node.AddPoolTx(parent, txpool.TxPool{Fee: 200, Vsize: 200})
+	node.AddPoolTx(mid, txpool.TxPool{Fee: 4000, Vsize: 200})
+	node.AddPoolTx(child, txpool.TxPool{Fee: 20_000, Vsize: 200})
+	tick(c)
+
+	pool := poolByTxid(c)
+	tests := []struct {
+		name                string
+		txid                string
+		rate                float64
+		ancCount, descCount int
+		ancSize             uint64
+		ancFees             uint64
+	}{
+		{"parent", parent.Txid, 50.5, 1, 2, 200, 200},
+		{"child", child.Txid, 50.5, 2, 1, 400, 20_200},
+		{"mid", mid.Txid, 20, 1, 1, 200, 4000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pool[tt.txid]
+			if !ok {
+				t.Fatal("not in the pool")
+			}
+			if got.EffectiveFeeRate != tt.rate || got.FeeRate() != tt.rate {
+				t.Errorf("effective fee rate %v, want %v", got.EffectiveFeeRate, tt.rate)
+			}
+			if got.AncestorCount != tt.ancCount || got.AncestorSize != tt.ancSize || got.AncestorFees != tt.ancFees {
+				t.Errorf("ancestors %d/%d/%d, want %d/%d/%d", got.AncestorCount, got.AncestorSize, got.AncestorFees, tt.ancCount, tt.ancSize, tt.ancFees)
+			}
+			if got.DescendantCount != tt.descCount {
+				t.Errorf("descendants %d, want %d", got.DescendantCount, tt.descCount)
+			}
+			if !got.Fits {
+				t.Error("does not fit the next block")
+			}
+		})
+	}
+
+	// package goes first, parent before the child
+	blocks := c.GetProjectedBlocks()
+	if len(blocks) != 1 {
+		t.Fatalf("projected %d blocks, want 1", len(blocks))
+	}
+	want := []string{parent.Txid, child.Txid, mid.Txid}
+	if fmt.Sprint(blocks[0].Txids) != fmt.Sprint(want) {
+		t.Fatalf("projected txids %v, want %v", blocks[0].Txids, want)
+	}
+	if blocks[0].Fee != 24_200 || blocks[0].Weight != 2400 || blocks[0].FeeRateMin != 20 || blocks[0].FeeRateMax != 50.5 {
+		t.Fatalf("projected block %+v", blocks[0])
+	}
+	// pool does not fill the block
+	if rec := c.GetFeeRecommended(); rec != minimumFees() {
+		t.Fatalf("recommended %+v, want the minimum", rec)
+	}
+}
+
+// blocks are filled up to the weight limit
+func TestProjectorWeightLimit(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 20_000)
+	cfg := testConfig()
+	cfg.RpcBatchSize = 1000
+	c := newTestCore(t, cfg, node)
+
+	addPoolTxs(node, funding, 0, 20_000, 1000, 2)
+	tick(c)
+	if n := c.GetPoolSize(); n != 20_000 {
+		t.Fatalf("pool %d, want 20000", n)
+	}
+	blocks := c.GetProjectedBlocks()
+	if len(blocks) != 5 {
+		t.Fatalf("projected %d blocks, want 5", len(blocks))
+	}
+	for i, b := range blocks {
+		if b.Index != i || b.Txs != 4000 || b.Weight != config.BLOCK_SIZE || b.VSize != config.BLOCK_SIZE/4 || b.Fee != 4000*500 {
+			t.Fatalf("block %d: index %d, %d txs, weight %d, vsize %d, fee %d", i, b.Index, b.Txs, b.Weight, b.VSize, b.Fee)
+		}
+	}
+}
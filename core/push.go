@@ -0,0 +1,144 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// pool and blocks updates pushed by the node, zmq or btcd websocket.
+// while any of the sources is connected polling is only for the reconciliation,
+// blocks always trigger the full pool pull
+
+func (c *Core) pushState(connected bool) {
+	if connected {
+		atomic.AddInt32(&c.pushSources, 1)
+		// catch up with what was missed while disconnected
+		c.pushBlock()
+		return
+	}
+	atomic.AddInt32(&c.pushSources, -1)
+}
+
+func (c *Core) pushActive() bool {
+	return atomic.LoadInt32(&c.pushSources) > 0
+}
+
+// wake up the block parser and the pool puller
+func (c *Core) pushBlock() {
+	select {
+	case c.blocksPullCh <- struct{}{}:
+	default:
+	}
+	select {
+	case c.poolPullCh <- struct{}{}:
+	default:
+	}
+}
+
+// burst of pushes while the entries are fetched
+const pushQueueSize = 10_000
+
+// pushed pool change, handled by the push worker in the order of arrival
+type pushedTx struct {
+	txid    string
+	btx     *tx.Transaction
+	removed bool
+}
+
+// new pool tx, parsed right away if the raw tx is pushed too.
+// called from the read loops of the push sources, never blocks
+func (c *Core) pushTxAdded(txid string, btx *tx.Transaction) {
+	c.queuePush(pushedTx{txid: txid, btx: btx})
+}
+
+func (c *Core) pushTxRemoved(txid string) {
+	c.queuePush(pushedTx{txid: txid, removed: true})
+}
+
+// the puller reconciles the dropped ones
+func (c *Core) queuePush(p pushedTx) {
+	select {
+	case c.pushCh <- p:
+	default:
+		logger.Log.WithField("context", "[push]").Warnf("push queue is full, dropped %s\n", p.txid)
+	}
+}
+
+// fetches the pool entries and hands the txs to the parser off the read loops
+func (c *Core) workerPush() {
+	log := logger.Log.WithField("context", "[workerPush]")
+	log.Info("started")
+	defer func() {
+		log.Info("stopped")
+	}()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case p := <-c.pushCh:
+			if p.removed {
+				c.removePushedTx(p.txid)
+				continue
+			}
+			c.addPushedTx(p.txid, p.btx)
+		}
+	}
+}
+
+func (c *Core) addPushedTx(txid string, btx *tx.Transaction) {
+	c.mu.Lock()
+	_, exists := c.poolCopyMap[txid]
+	c.mu.Unlock()
+	if exists {
+		return
+	}
+	// fee and time are only in the pool entry
+	entry, err := c.cli.MempoolEntry(c.ctx, txid)
+	if err != nil {
+		// already mined or replaced
+		if !client.IsNotFound(err) {
+			logger.Log.Errorf("error on mempool entry %s: %v\n", txid, err)
+		}
+		return
+	}
+	c.mu.Lock()
+	// pulled meanwhile
+	if _, ok := c.poolCopyMap[txid]; ok {
+		c.mu.Unlock()
+		return
+	}
+	// ordered by the sorter
+	c.poolCopyMap[txid] = *entry
+	height := c.height
+	c.mu.Unlock()
+	c.removals.Back(txid)
+	c.confirmations.Seen(txid, time.Unix(entry.Time, 0), height)
+
+	if btx == nil {
+		c.parserJobCh <- txid
+		return
+	}
+	c.prevouts.Add(txid, btx.GetOutValues())
+	parsed, inPool, err := c.txFromRaw(txid, btx)
+	if err != nil {
+		logger.Log.Errorf("error on parsing tx %s: %v\n", txid, err)
+		return
+	}
+	c.saveTx(parsed, inPool)
+}
+
+func (c *Core) removePushedTx(txid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.poolCopyMap[txid]
+	if !ok {
+		return
+	}
+	delete(c.poolCopyMap, txid)
+	c.removals.Removed([]txpool.TxPool{entry}, c.poolCopyMap)
+}
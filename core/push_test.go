@@ -0,0 +1,145 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	"github.com/1F47E/go-feesh/zmq"
+)
+
+// pool copy is updated by the zmq pushes and the puller at the same time, run with -race
+func TestPushWhilePolling(t *testing.T) {
+	pub, err := zmq.NewPublisher("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	node := client.NewFakeNode()
+	funding := testFunding(node, 300)
+	cfg := testConfig()
+	cfg.ZmqAddress = pub.Addr()
+	c := newTestCore(t, cfg, node)
+
+	go c.workerZmq()
+	go c.workerPush()
+	go c.workerPoolPuller(2 * time.Millisecond)
+	go c.workerTxParser(1)
+	waitFor(t, "zmq connected", c.pushActive)
+
+	publish := func(txid string, label byte) {
+		h, _ := hex.DecodeString(txid)
+		pub.Publish(zmq.TopicSequence, append(h, label))
+	}
+	for i := 0; i < 300; i++ {
+		t1 := testTx(i, funding)
+		node.AddPoolTx(t1, txpool.TxPool{Fee: 1000, Vsize: 200})
+		publish(t1.Txid, zmq.SequenceTxAdded)
+		if i%3 == 2 {
+			gone := testTx(i-1, funding).Txid
+			node.RemovePoolTx(gone)
+			publish(gone, zmq.SequenceTxRemoved)
+		}
+		if i%100 == 99 {
+			node.Mine(t1.Txid)
+		}
+		time.Sleep(200 * time.Microsecond)
+	}
+
+	// reconciled with the node once the pushes stop
+	waitFor(t, "pool copy matches the node", func() bool {
+		pool, _ := node.RawMempool(context.Background())
+		info, _ := node.GetInfo(context.Background())
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(pool) != len(c.poolCopyMap) || c.height != info.Blocks {
+			return false
+		}
+		for _, e := range pool {
+			if _, ok := c.poolCopyMap[e.Txid]; !ok {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// node that answers the pool entries once released
+type slowEntryNode struct {
+	client.Node
+	release chan struct{}
+}
+
+func (n *slowEntryNode) MempoolEntry(ctx context.Context, txid string) (*txpool.TxPool, error) {
+	<-n.release
+	return n.Node.MempoolEntry(ctx, txid)
+}
+
+// read loop of the push source is not held by the entry fetch and the parser handoff
+func TestPushNotBlocking(t *testing.T) {
+	fake := client.NewFakeNode()
+	funding := testFunding(fake, 10)
+	node := &slowEntryNode{Node: fake, release: make(chan struct{})}
+	c := newTestCore(t, testConfig(), node)
+	go c.workerPush()
+
+	txids := make([]string, 10)
+	for i := range txids {
+		tx := testTx(i, funding)
+		fake.AddPoolTx(tx, txpool.TxPool{Fee: 1000, Vsize: 200})
+		txids[i] = tx.Txid
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, txid := range txids {
+			c.pushTxAdded(txid, nil)
+		}
+		c.pushTxRemoved(txids[0])
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("push blocked on the pool entry")
+	}
+
+	// no parser running, the handoff waits in the worker
+	close(node.release)
+	waitFor(t, "first pushed tx in the pool copy", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_, ok := c.poolCopyMap[txids[0]]
+		return ok
+	})
+	go c.workerTxParser(1)
+	waitFor(t, "pushes applied in order", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_, removed := c.poolCopyMap[txids[0]]
+		return len(c.poolCopyMap) == len(txids)-1 && !removed
+	})
+	c.removals.mu.Lock()
+	_, pending := c.removals.pending[txids[0]]
+	c.removals.mu.Unlock()
+	if !pending {
+		t.Fatal("pushed removal is not pending")
+	}
+	waitFor(t, "parsed txs in the sorted pool", func() bool {
+		c.sortPool()
+		return c.GetPoolSize() == len(txids)-1
+	})
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	mrbf "github.com/1F47E/go-feesh/entity/models/rbf"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+// how long to remember outputs spent by the txs that left the pool.
+// replacement can be parsed a bit later than the original disappears
+var rbfRetention = 1 * time.Hour
+var rbfHistoryLimit = 1000
+
+type rbfTx struct {
+	fee     uint64
+	spends  []string
+	removed time.Time // when the tx left the pool, zero while in pool
+}
+
+// rbfTracker keeps the outputs spent by pool txs and detects conflicting spends
+type rbfTracker struct {
+	mu         *sync.Mutex
+	retention  time.Duration
+	txs        map[string]rbfTx
+	spentBy    map[string]string // outpoint -> txid
+	replacedBy map[string]string // original txid -> replacement txid
+	replaces   map[string]string // replacement txid -> original txid
+	history    []mrbf.Replacement
+	byTxid     map[string]mrbf.Replacement // original txid -> replacement record
+}
+
+func newRbfTracker() *rbfTracker {
+	return &rbfTracker{
+		mu:         &sync.Mutex{},
+		retention:  rbfRetention,
+		txs:        make(map[string]rbfTx),
+		spentBy:    make(map[string]string),
+		replacedBy: make(map[string]string),
+		replaces:   make(map[string]string),
+		history:    make([]mrbf.Replacement, 0),
+		byTxid:     make(map[string]mrbf.Replacement),
+	}
+}
+
+// add pool tx, returns replacements if the tx spends outputs already spent by others
+func (r *rbfTracker) Track(tx mtx.Tx) []mrbf.Replacement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.txs[tx.Hash]; ok {
+		return nil
+	}
+	now := time.Now()
+	r.txs[tx.Hash] = rbfTx{fee: tx.Fee, spends: tx.Spends}
+
+	res := make([]mrbf.Replacement, 0)
+	replaced := make(map[string]bool)
+	for _, op := range tx.Spends {
+		prev, ok := r.spentBy[op]
+		r.spentBy[op] = tx.Hash
+		if !ok || prev == tx.Hash || replaced[prev] {
+			continue
+		}
+		replaced[prev] = true
+		old := r.txs[prev]
+		rep := mrbf.Replacement{
+			Txid:       prev,
+			ReplacedBy: tx.Hash,
+			Fee:        old.fee,
+			FeeNew:     tx.Fee,
+			FeeDelta:   int64(tx.Fee) - int64(old.fee),
+			Time:       now,
+		}
+		r.replacedBy[prev] = tx.Hash
+		r.replaces[tx.Hash] = prev
+		r.byTxid[prev] = rep
+		r.history = append(r.history, rep)
+		res = append(res, rep)
+	}
+	if len(r.history) > rbfHistoryLimit {
+		r.history = r.history[len(r.history)-rbfHistoryLimit:]
+	}
+	return res
+}
+
+// forget txs that left the pool long ago.
+// retention counts from the removal, tx can sit in the pool for days before its replaced
+func (r *rbfTracker) Prune(pool map[string]bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	deadline := now.Add(-r.retention)
+	for txid, t := range r.txs {
+		if pool[txid] {
+			// back to the pool after the reorg
+			if !t.removed.IsZero() {
+				t.removed = time.Time{}
+				r.txs[txid] = t
+			}
+			continue
+		}
+		if t.removed.IsZero() {
+			t.removed = now
+			r.txs[txid] = t
+			continue
+		}
+		if t.removed.After(deadline) {
+			continue
+		}
+		for _, op := range t.spends {
+			if r.spentBy[op] == txid {
+				delete(r.spentBy, op)
+			}
+		}
+		delete(r.txs, txid)
+		if next, ok := r.replacedBy[txid]; ok {
+			delete(r.replaces, next)
+		}
+		if prev, ok := r.replaces[txid]; ok {
+			delete(r.replacedBy, prev)
+			delete(r.byTxid, prev)
+		}
+		delete(r.replacedBy, txid)
+		delete(r.replaces, txid)
+		delete(r.byTxid, txid)
+	}
+}
+
+// was the tx replaced
+func (r *rbfTracker) ReplacedBy(txid string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next, ok := r.replacedBy[txid]
+	return next, ok
+}
+
+// recent replacements, new first
+func (r *rbfTracker) History(limit int) []mrbf.Replacement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]mrbf.Replacement, 0, limit)
+	for i := len(r.history) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, r.history[i])
+	}
+	return res
+}
+
+// full replacement chain the tx belongs to, from the first original to the last replacement
+func (r *rbfTracker) Chain(txid string) []mrbf.Replacement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// walk back to the first tx in chain
+	root := txid
+	seen := map[string]bool{root: true}
+	for {
+		prev, ok := r.replaces[root]
+		if !ok || seen[prev] {
+			break
+		}
+		seen[prev] = true
+		root = prev
+	}
+	res := make([]mrbf.Replacement, 0)
+	seen = map[string]bool{root: true}
+	for cur := root; ; {
+		rep, ok := r.byTxid[cur]
+		if !ok || seen[rep.ReplacedBy] {
+			break
+		}
+		seen[rep.ReplacedBy] = true
+		res = append(res, rep)
+		cur = rep.ReplacedBy
+	}
+	return res
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+)
+
+// original sat in the pool longer than the retention, replacement is parsed after it left
+func TestRbfPruneAfterRemoval(t *testing.T) {
+	r := newRbfTracker()
+	r.retention = 50 * time.Millisecond
+	r.Track(mtx.Tx{Hash: "a", Fee: 1000, Spends: []string{"x:0"}})
+	r.Prune(map[string]bool{"a": true})
+	time.Sleep(2 * r.retention)
+	r.Prune(map[string]bool{"a": true})
+
+	// replaced, the original is gone before the replacement is parsed
+	r.Prune(map[string]bool{})
+	reps := r.Track(mtx.Tx{Hash: "b", Fee: 2000, Spends: []string{"x:0"}})
+	if len(reps) != 1 || reps[0].Txid != "a" || reps[0].FeeDelta != 1000 {
+		t.Fatalf("replacement not detected: %+v", reps)
+	}
+	if next, ok := r.ReplacedBy("a"); !ok || next != "b" {
+		t.Fatalf("replaced by %q %v, want b", next, ok)
+	}
+
+	// forgotten once the retention passed since the removal
+	r.Prune(map[string]bool{"b": true})
+	time.Sleep(2 * r.retention)
+	r.Prune(map[string]bool{"b": true})
+	if _, ok := r.ReplacedBy("a"); ok {
+		t.Fatal("original is still tracked")
+	}
+	if _, ok := r.txs["b"]; !ok {
+		t.Fatal("pool tx is pruned")
+	}
+}
+
+// tx back to the pool after the reorg is not pruned
+func TestRbfPruneBackToPool(t *testing.T) {
+	r := newRbfTracker()
+	r.retention = 50 * time.Millisecond
+	r.Track(mtx.Tx{Hash: "a", Fee: 1000, Spends: []string{"x:0"}})
+	r.Prune(map[string]bool{})
+	r.Prune(map[string]bool{"a": true})
+	time.Sleep(2 * r.retention)
+	r.Prune(map[string]bool{"a": true})
+	if _, ok := r.txs["a"]; !ok {
+		t.Fatal("pool tx is pruned")
+	}
+}
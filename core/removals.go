@@ -0,0 +1,196 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	mremoval "github.com/1F47E/go-feesh/entity/models/removal"
+)
+
+// removed tx not found in blocks or replaced within this time is considered evicted
+var removalGracePeriod = 2 * time.Minute
+
+// how long to remember txs of the parsed blocks
+var confirmedRetention = 1 * time.Hour
+var removalsLogLimit = 1000
+
+type confirmedTx struct {
+	blockHash   string
+	blockHeight int
+	time        time.Time
+}
+
+type pendingRemoval struct {
+	tx   txpool.TxPool
+	time time.Time
+}
+
+// removalTracker classifies txs that left the pool
+type removalTracker struct {
+	mu        *sync.Mutex
+	pending   map[string]pendingRemoval
+	confirmed map[string]confirmedTx
+	counters  map[mremoval.Reason]uint64
+	log       []mremoval.Removal
+	byTxid    map[string]mremoval.Removal
+	resolved  map[string]pendingRemoval // confirmed ones of the log, pending again if the block is orphaned
+}
+
+func newRemovalTracker() *removalTracker {
+	return &removalTracker{
+		mu:        &sync.Mutex{},
+		pending:   make(map[string]pendingRemoval),
+		confirmed: make(map[string]confirmedTx),
+		counters:  make(map[mremoval.Reason]uint64),
+		log:       make([]mremoval.Removal, 0),
+		byTxid:    make(map[string]mremoval.Removal),
+		resolved:  make(map[string]pendingRemoval),
+	}
+}
+
+// txs that disappeared from the pool, reason is decided later.
+// the ones that came back to the pool are not pending anymore, pool is read under the core lock
+func (r *removalTracker) Removed(txs []txpool.TxPool, pool map[string]txpool.TxPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for txid := range r.pending {
+		if _, ok := pool[txid]; ok {
+			delete(r.pending, txid)
+		}
+	}
+	for _, tx := range txs {
+		r.pending[tx.Txid] = pendingRemoval{tx: tx, time: now}
+	}
+}
+
+// pushed back to the pool, not removed anymore
+func (r *removalTracker) Back(txid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, txid)
+}
+
+// txs of the newly parsed block
+func (r *removalTracker) Confirm(blockHash string, blockHeight int, txids []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, txid := range txids {
+		r.confirmed[txid] = confirmedTx{blockHash: blockHash, blockHeight: blockHeight, time: now}
+	}
+}
+
+// block was orphaned, its txs are back to the pool or mined in the new chain.
+// removals confirmed by the block are pending again, the reverted ones are returned
+func (r *removalTracker) Unconfirm(blockHash string, txids []string) []mremoval.Removal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, txid := range txids {
+		delete(r.confirmed, txid)
+	}
+	reverted := make([]mremoval.Removal, 0)
+	log := make([]mremoval.Removal, 0, len(r.log))
+	for _, rm := range r.log {
+		if rm.Reason != mremoval.ReasonConfirmed || rm.BlockHash != blockHash {
+			log = append(log, rm)
+			continue
+		}
+		r.counters[rm.Reason]--
+		if r.byTxid[rm.Txid] == rm {
+			delete(r.byTxid, rm.Txid)
+		}
+		if p, ok := r.resolved[rm.Txid]; ok {
+			// grace period from now, the pool pull can bring it back
+			p.time = now
+			r.pending[rm.Txid] = p
+			delete(r.resolved, rm.Txid)
+		}
+		reverted = append(reverted, rm)
+	}
+	r.log = log
+	return reverted
+}
+
+// classify pending removals, returns the resolved ones
+func (r *removalTracker) Resolve(rbf *rbfTracker) []mremoval.Removal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	res := make([]mremoval.Removal, 0)
+	for txid, p := range r.pending {
+		rm := mremoval.Removal{
+			Txid:      txid,
+			Fee:       p.tx.Fee,
+			FeeRate:   p.tx.FeeRate(),
+			FirstSeen: time.Unix(p.tx.Time, 0),
+			Time:      p.time,
+		}
+		if conf, ok := r.confirmed[txid]; ok {
+			rm.Reason = mremoval.ReasonConfirmed
+			rm.BlockHash = conf.blockHash
+			rm.BlockHeight = conf.blockHeight
+		} else if next, ok := rbf.ReplacedBy(txid); ok {
+			rm.Reason = mremoval.ReasonReplaced
+			rm.ReplacedBy = next
+		} else if now.Sub(p.time) > removalGracePeriod {
+			rm.Reason = mremoval.ReasonEvicted
+		} else {
+			continue
+		}
+		delete(r.pending, txid)
+		if rm.Reason == mremoval.ReasonConfirmed {
+			r.resolved[txid] = p
+		}
+		r.counters[rm.Reason]++
+		r.log = append(r.log, rm)
+		r.byTxid[txid] = rm
+		res = append(res, rm)
+	}
+
+	// cleanup
+	if len(r.log) > removalsLogLimit {
+		for _, rm := range r.log[:len(r.log)-removalsLogLimit] {
+			delete(r.byTxid, rm.Txid)
+			delete(r.resolved, rm.Txid)
+		}
+		r.log = r.log[len(r.log)-removalsLogLimit:]
+	}
+	deadline := now.Add(-confirmedRetention)
+	for txid, conf := range r.confirmed {
+		if conf.time.Before(deadline) {
+			delete(r.confirmed, txid)
+		}
+	}
+	return res
+}
+
+func (r *removalTracker) Counters() map[mremoval.Reason]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make(map[mremoval.Reason]uint64, len(r.counters))
+	for k, v := range r.counters {
+		res[k] = v
+	}
+	return res
+}
+
+// recent removals, new first
+func (r *removalTracker) Recent(limit int) []mremoval.Removal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]mremoval.Removal, 0, limit)
+	for i := len(r.log) - 1; i >= 0 && len(res) < limit; i-- {
+		res = append(res, r.log[i])
+	}
+	return res
+}
+
+func (r *removalTracker) Get(txid string) (mremoval.Removal, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rm, ok := r.byTxid[txid]
+	return rm, ok
+}
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"time"
+
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// compare parsed blocks with the best chain by height.
+// chain is height -> hash of the last blocks of the best chain
+func (c *Core) detectReorg(chain map[int]string, tipHeight int) *mblock.Reorg {
+	c.mu.Lock()
+	stale := make([]string, 0)
+	forkHeight := tipHeight
+	for height, hash := range c.hashByHeight {
+		// new chain can be shorter
+		chainHash, ok := chain[height]
+		if (ok && chainHash != hash) || height > tipHeight {
+			stale = append(stale, hash)
+			if height-1 < forkHeight {
+				forkHeight = height - 1
+			}
+		}
+	}
+	c.mu.Unlock()
+	if len(stale) == 0 {
+		return nil
+	}
+	// fork point below the pulled headers
+	forkHash, ok := chain[forkHeight]
+	if !ok {
+		hash, err := c.cli.GetBlockHash(c.ctx, forkHeight)
+		if err != nil {
+			logger.Log.Errorf("error on getblockhash %d: %v\n", forkHeight, err)
+		}
+		forkHash = hash
+	}
+	return &mblock.Reorg{
+		ForkHeight: forkHeight,
+		ForkHash:   forkHash,
+		Depth:      len(stale),
+		Stale:      stale,
+		Tip:        chain[tipHeight],
+		Time:       time.Now(),
+	}
+}
+
+// drop orphaned blocks, their txs are back to the pool
+func (c *Core) rollbackBlocks(stale []string) {
+	log := logger.Log.WithField("context", "[rollbackBlocks]")
+	c.dropBlocks(stale)
+	for _, hash := range stale {
+		txs, err := c.storage.BlockGet(hash)
+		if err != nil {
+			log.Errorf("error on blockget %s: %v\n", hash, err)
+		}
+		for _, rm := range c.removals.Unconfirm(hash, txs) {
+			c.confirmations.Revert(rm)
+		}
+		c.dropHealth(hash)
+		if err := c.storage.BlockDelete(hash); err != nil {
+			log.Errorf("error on blockdelete %s: %v\n", hash, err)
+		}
+		if err := c.storage.BlockStatsDelete(hash); err != nil {
+			log.Errorf("error on blockstatsdelete %s: %v\n", hash, err)
+		}
+		log.Infof("block %s rolled back, %d txs back to pool\n", hash, len(txs))
+	}
+}
+
+// stats of the blocks that are not in the best chain, the rollback misses
+// the reorgs while stopped and the ones deeper than the parsed blocks.
+// chain is height -> hash of the best chain
+func (c *Core) deleteOrphanedStats(chain map[int]string) {
+	log := logger.Log.WithField("context", "[deleteOrphanedStats]")
+	if len(chain) == 0 {
+		return
+	}
+	from, to := -1, -1
+	for height := range chain {
+		if from < 0 || height < from {
+			from = height
+		}
+		if height > to {
+			to = height
+		}
+	}
+	stats, err := c.storage.BlockStatsRange(from, to)
+	if err != nil {
+		log.Errorf("error on blockstatsrange: %v\n", err)
+		return
+	}
+	for _, b := range stats {
+		hash, ok := chain[b.Height]
+		if !ok || hash == b.Hash {
+			continue
+		}
+		log.Warnf("block %s at height %d is orphaned, stats deleted\n", b.Hash, b.Height)
+		if err := c.storage.BlockStatsDelete(b.Hash); err != nil {
+			log.Errorf("error on blockstatsdelete %s: %v\n", b.Hash, err)
+		}
+	}
+}
+
+// keep in mem only the blocks of the chain window
+func (c *Core) trimBlocks(chain map[int]string) {
+	inChain := make(map[string]bool, len(chain))
+	for _, hash := range chain {
+		inChain[hash] = true
+	}
+	old := make([]string, 0)
+	c.mu.Lock()
+	for _, hash := range c.blocksIndex {
+		if !inChain[hash] {
+			old = append(old, hash)
+		}
+	}
+	c.mu.Unlock()
+	if len(old) > 0 {
+		logger.Log.Debugf("dropping %d old blocks from mem\n", len(old))
+		c.dropBlocks(old)
+	}
+}
+
+// remove blocks from mem index
+func (c *Core) dropBlocks(hashes []string) {
+	drop := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		drop[hash] = true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index := make([]string, 0, len(c.blocksIndex))
+	for _, hash := range c.blocksIndex {
+		if !drop[hash] {
+			index = append(index, hash)
+		}
+	}
+	c.blocksIndex = index
+	blocks := make([]mblock.Block, 0, len(c.blocks))
+	for _, b := range c.blocks {
+		if !drop[b.Hash] {
+			blocks = append(blocks, b)
+		}
+	}
+	c.blocks = blocks
+	for height, hash := range c.hashByHeight {
+		if drop[hash] {
+			delete(c.hashByHeight, height)
+		}
+	}
+	for _, hash := range hashes {
+		delete(c.blockHeaders, hash)
+	}
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	mremoval "github.com/1F47E/go-feesh/entity/models/removal"
+)
+
+// same as the removals worker
+func resolveRemovals(c *Core) {
+	for _, rm := range c.removals.Resolve(c.rbf) {
+		c.confirmations.Add(rm)
+	}
+}
+
+func confirmationSamples(c *Core) int {
+	n := 0
+	for _, ct := range c.GetConfirmationTimes() {
+		n += ct.Samples
+	}
+	return n
+}
+
+// confirmation by the orphaned block is reverted, the tx is confirmed again by the new chain
+func TestReorgRevertsConfirmation(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+	// pool entries are in seconds, seen right after the start
+	c.confirmations.startedAt = time.Time{}
+
+	a := testTx(0, funding)
+	node.AddPoolTx(a, txpool.TxPool{Fee: 900_000, Vsize: 200, Time: time.Now().Unix()})
+	tick(c)
+	seenHeight := c.GetHeight()
+
+	stale := node.Mine(a.Txid)
+	tick(c)
+	resolveRemovals(c)
+	rm, ok := c.GetTxRemoval(a.Txid)
+	if !ok || rm.Reason != mremoval.ReasonConfirmed || rm.BlockHash != stale.Hash {
+		t.Fatalf("removal %+v, %v, want confirmed by %s", rm, ok, stale.Hash)
+	}
+	if n := c.GetRemovalCounters()[mremoval.ReasonConfirmed]; n != 1 {
+		t.Fatalf("confirmed counter %d, want 1", n)
+	}
+	if n := confirmationSamples(c); n != 1 {
+		t.Fatalf("confirmation samples %d, want 1", n)
+	}
+	if h := c.GetBlocksHealth(10); len(h) != 1 || h[0].Hash != stale.Hash {
+		t.Fatalf("health %+v, want the stale tip", h)
+	}
+
+	// tip replaced, a is back to the pool
+	node.Reorg(1)
+	node.AddPoolTx(a, txpool.TxPool{Fee: 900_000, Vsize: 200, Time: time.Now().Unix()})
+	node.Mine()
+	tick(c)
+	resolveRemovals(c)
+	if rm, ok := c.GetTxRemoval(a.Txid); ok {
+		t.Fatalf("removal of the orphaned block is kept: %+v", rm)
+	}
+	if n := c.GetRemovalCounters()[mremoval.ReasonConfirmed]; n != 0 {
+		t.Fatalf("confirmed counter %d after the reorg, want 0", n)
+	}
+	if len(c.GetRemovals(10)) != 0 {
+		t.Fatalf("removals log %+v", c.GetRemovals(10))
+	}
+	if n := confirmationSamples(c); n != 0 {
+		t.Fatalf("confirmation samples %d after the reorg, want 0", n)
+	}
+	for _, h := range c.GetBlocksHealth(10) {
+		if h.Hash == stale.Hash {
+			t.Fatalf("health of the orphaned tip is kept: %+v", h)
+		}
+	}
+	if h, _ := c.GetBlockHealth(stale.Hash); h != nil {
+		t.Fatalf("health of the orphaned tip is stored: %+v", h)
+	}
+	if !poolTxids(c)[a.Txid] {
+		t.Fatal("tx of the orphaned block is not in the pool")
+	}
+
+	// mined by the new chain, waited since it was first seen
+	mined := node.Mine(a.Txid)
+	tick(c)
+	resolveRemovals(c)
+	rm, ok = c.GetTxRemoval(a.Txid)
+	if !ok || rm.Reason != mremoval.ReasonConfirmed || rm.BlockHash != mined.Hash {
+		t.Fatalf("removal %+v, %v, want confirmed by %s", rm, ok, mined.Hash)
+	}
+	times := c.GetConfirmationTimes()
+	if len(times) != 1 || times[0].Samples != 1 || times[0].Blocks.P50 != float64(mined.Height-seenHeight) {
+		t.Fatalf("confirmation times %+v, want %d blocks", times, mined.Height-seenHeight)
+	}
+}
+
+// fork point below the pulled headers is asked from the node
+func TestReorgForkHash(t *testing.T) {
+	node := client.NewFakeNode()
+	testFunding(node, 1)
+	c := newTestCore(t, testConfig(), node)
+	tick(c)
+	tip := c.GetHeight()
+
+	reorg := c.detectReorg(map[int]string{tip: "new"}, tip)
+	if reorg == nil || reorg.ForkHeight != tip-1 {
+		t.Fatalf("reorg %+v, want fork at %d", reorg, tip-1)
+	}
+	want, _ := node.GetBlockHash(c.ctx, tip-1)
+	if reorg.ForkHash != want {
+		t.Fatalf("fork hash %q, want %q", reorg.ForkHash, want)
+	}
+}
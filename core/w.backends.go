@@ -0,0 +1,26 @@
+package core
+
+import (
+	"time"
+
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// check the RPC backends and switch to the synced one
+func (c *Core) workerBackends(period time.Duration) {
+	log := logger.Log.WithField("context", "[workerBackends]")
+	log.Info("started")
+	ticker := time.NewTicker(period)
+	defer func() {
+		log.Info("stopped")
+		ticker.Stop()
+	}()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.cli.HealthCheck(c.ctx)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package core
 import (
 	"time"
 
-	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	"github.com/1F47E/go-feesh/entity/btc/block"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
 	"github.com/1F47E/go-feesh/logger"
+	"github.com/1F47E/go-feesh/notificator"
 )
 
 func (c *Core) workerParserBlocks(period time.Duration) {
@@ -17,86 +19,137 @@ func (c *Core) workerParserBlocks(period time.Duration) {
 	}()
 
 	// WARN: debug reset
-	c.height = 0
+	c.setHeight(0)
 
+	reconcile := time.Duration(c.Cfg.ReconcilePeriod) * time.Second
+	var lastPoll time.Time
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
-			// get the block height
-			info, err := c.cli.GetInfo()
-			if err != nil {
-				log.Errorf("error on getinfo: %v\n", err)
+			// updates are pushed, poll only to reconcile
+			if c.pushActive() && time.Since(lastPoll) < reconcile {
 				continue
 			}
-			// skip if initial blocks already parsed and no new blocks
-			if c.height == info.Blocks && len(c.blocks) > 0 {
-				continue
-			}
-			c.height = info.Blocks
-			log.Debugf("new block height: %d\n", info.Blocks)
+		case <-c.blocksPullCh:
+			// pushed by the node, do not wait for the tick
+		}
+		lastPoll = time.Now()
+		// send block txs parser
+		for _, txid := range c.pullBlocks() {
+			c.parserJobCh <- txid
+		}
+	}
+}
 
-			// get best block
-			best, err := c.cli.GetBestBlock()
-			if err != nil {
-				log.Errorf("error on getbestblock: %v\n", err)
-				continue
-			}
+// check the tip, roll back the orphaned blocks and add the new ones.
+// returns txs of the new blocks to parse
+func (c *Core) pullBlocks() []string {
+	log := logger.Log.WithField("context", "[workerParserBlocks]")
+	// get best block
+	// tip hash is checked, not the height - tip can be replaced on the same height
+	best, err := c.cli.GetBestBlock(c.ctx)
+	if err != nil {
+		log.Errorf("error on getbestblock: %v\n", err)
+		return nil
+	}
+	// skip if initial blocks already parsed and no new blocks
+	c.mu.Lock()
+	parsed := len(c.blocks) > 0
+	c.mu.Unlock()
+	if c.bestHash == best.Hash && parsed {
+		return nil
+	}
+	c.bestHash = best.Hash
+	c.setHeight(best.Height)
+	log.Debugf("new block height: %d\n", best.Height)
 
-			if len(c.blocks) > c.blockDepth {
-				log.Warnf("blocks buffer is full. dropping oldest block. have %d blocks", len(c.blocks))
-				c.blocks = c.blocks[:len(c.blocks)-1]
-				continue
-			}
+	// collect N block hashes
+	// around 3k txs in a block and around 1.5Meg for txs data
+	blocks := make([]string, 0)
+	chain := make(map[int]string) // height -> hash of the current best chain
+	headers := make(map[string]*block.Block)
+	currentHash := best.Hash
+	for i := 0; i < c.blockDepth; i++ {
+		header, err := c.cli.GetBlockHeader(c.ctx, currentHash)
+		if err != nil {
+			log.Errorf("error on getblockheader: %v\n", err)
+			break
+		}
+		blocks = append(blocks, currentHash)
+		chain[header.Height] = currentHash
+		headers[currentHash] = header
+		// l.Debugf("best block hash: %s\n", best.Hash)
+		// l.Debugf("prev block hash: %s\n", header.Previousblockhash)
+		currentHash = header.Previousblockhash
+		// genesis, short chain on regtest
+		if currentHash == "" {
+			break
+		}
+	}
+	log.Debugf("got %d last blocks\n", len(blocks))
+	for _, hash := range blocks {
+		log.Debugf("block hash: %s\n", hash)
+	}
 
-			// collect N block hashes
-			// around 3k txs in a block and around 1.5Meg for txs data
-			blocks := make([]string, 0)
-			currentHash := best.Hash
-			for i := 0; i < c.blockDepth; i++ {
-				blocks = append(blocks, currentHash)
-				header, err := c.cli.GetBlockHeader(currentHash)
-				if err != nil {
-					log.Errorf("error on getblockheader: %v\n", err)
-					continue
-				}
-				// l.Debugf("best block hash: %s\n", best.Hash)
-				// l.Debugf("prev block hash: %s\n", header.Previousblockhash)
-				currentHash = header.Previousblockhash
-			}
-			log.Debugf("got %d last blocks\n", len(blocks))
-			for _, hash := range blocks {
-				log.Debugf("block hash: %s\n", hash)
-			}
+	// parsed blocks that are not in the best chain anymore
+	if reorg := c.detectReorg(chain, best.Height); reorg != nil {
+		log.Warnf("reorg detected at height %d, depth %d\n", reorg.ForkHeight, reorg.Depth)
+		c.rollbackBlocks(reorg.Stale)
+		go c.emit(notificator.EventReorg, *reorg)
+	}
 
-			// parse N blocks
-			now := time.Now()
-			for i, hash := range blocks {
-				// get full block data (tx list)
-				exists, _ := c.storage.BlockExists(hash)
-				if !exists {
-					log.Debugf("%d/%d block parsing: %s\n", i+1, len(blocks), hash)
-					b, err := c.cli.GetBlock(hash)
-					if err != nil {
-						log.Errorf("error on getblock: %v\n", err)
-					}
-					// TODO: store raw block info also
-					_ = c.storage.BlockAdd(b.Hash, b.Transactions)
-					// add to in mem blocks index
-					c.mu.Lock()
-					c.blocksIndex = append(c.blocksIndex, b.Hash)
-					c.mu.Unlock()
-					// send block txs parser
-					txs, _ := c.storage.BlockGet(b.Hash)
-					for _, txid := range txs {
-						c.parserJobCh <- txid
-					}
-				}
+	// parse N blocks
+	now := time.Now()
+	parse := make([]string, 0)
+	for i, hash := range blocks {
+		// get full block data (tx list)
+		c.mu.Lock()
+		_, indexed := c.blockHeaders[hash]
+		c.mu.Unlock()
+		if indexed {
+			continue
+		}
+		exists, _ := c.storage.BlockExists(hash)
+		if exists {
+			// stored before the restart, rebuild the index from storage
+			parse = append(parse, c.indexStoredBlock(headers[hash])...)
+			continue
+		}
+		log.Debugf("%d/%d block parsing: %s\n", i+1, len(blocks), hash)
+		b, err := c.cli.GetBlock(c.ctx, hash)
+		if err != nil {
+			log.Errorf("error on getblock: %v\n", err)
+			continue
+		}
+		// TODO: store raw block info also
+		_ = c.storage.BlockAdd(b.Hash, b.Transactions)
+		c.removals.Confirm(b.Hash, b.Height, b.Transactions)
+		// new tip, compare with what we expected to be mined
+		if b.Height == best.Height {
+			if h := c.blockHealth(b.Hash, b.Height, b.Transactions); h != nil {
+				log.Infof("block %d health: %.1f%%, missing %d, unexpected %d\n", h.Height, h.Match, len(h.Missing), len(h.Unexpected))
+				c.addHealth(*h)
 			}
-			log.Debugf("blocks %d processed in %s\n", len(blocks), time.Since(now))
 		}
+		// add to in mem blocks index
+		c.mu.Lock()
+		c.blocksIndex = append(c.blocksIndex, b.Hash)
+		c.hashByHeight[b.Height] = b.Hash
+		c.blockHeaders[b.Hash] = b
+		c.mu.Unlock()
+		txs, _ := c.storage.BlockGet(b.Hash)
+		parse = append(parse, txs...)
+	}
+	log.Debugf("blocks %d processed in %s\n", len(blocks), time.Since(now))
+	c.deleteOrphanedStats(chain)
+
+	// blocks buffer is full, drop the ones below the last N
+	if len(chain) == c.blockDepth {
+		c.trimBlocks(chain)
 	}
+	return parse
 }
 
 func (c *Core) workerBlocksProcessor(period time.Duration) {
@@ -111,53 +164,96 @@ func (c *Core) workerBlocksProcessor(period time.Duration) {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
-			// check blocks and what tx are parsed
-			txCnt := 0
-			if len(c.blocksIndex) == len(c.blocks) {
+			c.processBlocks()
+		}
+	}
+}
+
+// check blocks and what tx are parsed
+// incomplete blocks are processed again until all the txs are parsed
+func (c *Core) processBlocks() {
+	log := logger.Log.WithField("context", "[workerBlocksProcessor]")
+	txCnt := 0
+	c.mu.Lock()
+	index := make([]string, len(c.blocksIndex))
+	copy(index, c.blocksIndex)
+	c.mu.Unlock()
+	for _, hash := range index {
+		c.mu.Lock()
+		pos := c.blockPos(hash)
+		complete := pos >= 0 && c.blocks[pos].IsComplete()
+		hdr, ok := c.blockHeaders[hash]
+		c.mu.Unlock()
+		if complete || !ok {
+			continue
+		}
+		// log.Log.Debugf("checking block %s\n", hash)
+		txs, _ := c.storage.BlockGet(hash)
+		// log.Log.Debugf("block has %s txs: %d\n", hash, len(txs))
+		parsed := make([]*mtx.Tx, 0, len(txs))
+		for _, txid := range txs {
+			// check if tx is parsed
+			tx, _ := c.storage.TxGet(txid)
+			if tx == nil || !tx.IsComplete() {
 				continue
 			}
-			log.Info("processing blocks")
-			for _, hash := range c.blocksIndex {
-				var bWeight, bSize, bFee, bAmount uint64
-				// log.Log.Debugf("checking block %s\n", hash)
-				txs, _ := c.storage.BlockGet(hash)
-				// log.Log.Debugf("block has %s txs: %d\n", hash, len(txs))
-				cnt := 0
-				for _, txid := range txs {
-					// check if tx is parsed
-					tx, _ := c.storage.TxGet(txid)
-					if tx != nil {
-						// skip first one. TODO: detect coinbase by param
-						cnt++
-						if cnt == 1 {
-							continue
-						}
-						bWeight += uint64(tx.Weight)
-						bSize += uint64(tx.Size)
-						bFee += tx.Fee
-						bAmount += tx.AmountOut
-						log.Debugf("block %s tx %s fee %d amount %d\n", hash, txid, tx.Fee, tx.AmountOut)
-					}
-				}
-				log.Debugf("block %s has tx %d parsed. total fee: %d amount: %d\n", hash, cnt, bFee, bAmount)
-				txCnt += cnt
-				// save block stats
-				b := mblock.Block{
-					Hash:   hash,
-					Txs:    uint64(len(txs)),
-					Weight: bWeight,
-					Size:   bSize,
-					Fee:    bFee,
-					Value:  bAmount,
-				}
-				c.blocks = append(c.blocks, b)
-				log.Infof("block %s added to blocks list. cnt: %d\n", hash, cnt)
-				// TODO: add to storage
-				// l.Debugf("block %s has %d/%d txs parsed. Weight: %d, Amount: %d", hash, cnt, len(txs), bWeight, bAmount)
-			}
-			if txCnt > 0 {
-				log.Debugf("total parsed txs: %d\n", txCnt)
+			parsed = append(parsed, tx)
+		}
+		txCnt += len(parsed)
+		// save block stats
+		b := newBlockStats(hdr, c.prevBlockTime(hdr), len(txs), parsed)
+		log.Debugf("block %s has tx %d parsed. total fee: %d amount: %d\n", hash, len(parsed), b.Fee, b.Value)
+		c.mu.Lock()
+		// dropped meanwhile by the reorg
+		if _, ok := c.blockHeaders[hash]; !ok {
+			c.mu.Unlock()
+			continue
+		}
+		if pos := c.blockPos(hash); pos >= 0 {
+			c.blocks[pos] = b
+		} else {
+			c.blocks = append(c.blocks, b)
+			log.Infof("block %s added to blocks list. cnt: %d\n", hash, len(parsed))
+		}
+		c.mu.Unlock()
+		if b.IsComplete() {
+			log.Infof("block %s complete. fee: %s value: %s\n", hash, b.FeeString(), b.ValueString())
+			if err := c.storage.BlockStatsAdd(b); err != nil {
+				log.Errorf("error on blockstatsadd: %v\n", err)
 			}
 		}
 	}
+	if txCnt > 0 {
+		log.Debugf("total parsed txs: %d\n", txCnt)
+	}
+}
+
+// add the stored block to the in mem index.
+// returns the block txs to parse if the stats are not complete yet
+func (c *Core) indexStoredBlock(hdr *block.Block) []string {
+	stats, _ := c.storage.BlockStatsGet(hdr.Hash)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocksIndex = append(c.blocksIndex, hdr.Hash)
+	c.hashByHeight[hdr.Height] = hdr.Hash
+	c.blockHeaders[hdr.Hash] = hdr
+	if stats != nil && stats.IsComplete() {
+		if c.blockPos(hdr.Hash) < 0 {
+			c.blocks = append(c.blocks, *stats)
+		}
+		return nil
+	}
+	// txs could expire meanwhile, parser skips the complete ones
+	txs, _ := c.storage.BlockGet(hdr.Hash)
+	return txs
+}
+
+// position in the blocks list, -1 if not there. under the lock
+func (c *Core) blockPos(hash string) int {
+	for i := range c.blocks {
+		if c.blocks[i].Hash == hash {
+			return i
+		}
+	}
+	return -1
 }
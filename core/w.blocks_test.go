@@ -0,0 +1,154 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	smap "github.com/1F47E/go-feesh/storage/map"
+)
+
+func blockHashes(c *Core) map[string]bool {
+	ret := make(map[string]bool)
+	for _, b := range c.GetBlocks() {
+		ret[b.Hash] = true
+	}
+	return ret
+}
+
+func TestBlockWorkers(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+
+	// block txs never seen in the pool, fee is resolved from the prevouts
+	a, b := testTx(0, funding), testTx(1, funding)
+	node.AddTx(a)
+	node.AddTx(b)
+	var mined, stale string
+	node.Script(
+		func(f *client.FakeNode) {
+			mined = f.Mine(a.Txid).Hash
+		},
+		func(f *client.FakeNode) {
+			stale = f.Mine(b.Txid).Hash
+		},
+		// tip replaced, b is back to the pool
+		func(f *client.FakeNode) {
+			f.Reorg(1)
+			f.AddPoolTx(b, txpool.TxPool{Fee: 900_000, Vsize: 200})
+			f.Mine()
+		},
+	)
+
+	step(t, node, c)
+	blocks := c.GetBlocks()
+	if len(blocks) != 3 {
+		t.Fatalf("blocks %d, want 3", len(blocks))
+	}
+	for _, blk := range blocks {
+		if !blk.IsComplete() {
+			t.Fatalf("block %d is not complete: %+v", blk.Height, blk)
+		}
+	}
+	stats, _ := c.GetBlock(mined)
+	// 0.01 in, 0.001 out
+	if stats == nil || stats.Fee != 900_000 || stats.Txs != 2 {
+		t.Fatalf("mined block stats %+v", stats)
+	}
+
+	step(t, node, c)
+	if hashes := blockHashes(c); len(hashes) != 3 || !hashes[stale] || !hashes[mined] {
+		t.Fatalf("blocks %v", hashes)
+	}
+
+	// reorg
+	step(t, node, c)
+	hashes := blockHashes(c)
+	if len(hashes) != 3 || hashes[stale] || !hashes[mined] {
+		t.Fatalf("blocks after reorg %v", hashes)
+	}
+	if exists, _ := c.storage.BlockExists(stale); exists {
+		t.Fatal("stale block is in storage")
+	}
+	if stats, _ := c.storage.BlockStatsGet(stale); stats != nil {
+		t.Fatalf("stale block stats are in storage: %+v", stats)
+	}
+	if !poolTxids(c)[b.Txid] {
+		t.Fatal("tx of the stale block is not in the pool")
+	}
+	best, _ := node.GetBestBlock(c.ctx)
+	if c.GetHeight() != best.Height || !hashes[best.Hash] {
+		t.Fatalf("height %d, tip %s not parsed", c.GetHeight(), best.Hash)
+	}
+	if node.Next() {
+		t.Fatal("steps left")
+	}
+}
+
+// block is parsed again once the node has it
+func TestBlockWorkersGetBlockFails(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+	tick(c)
+
+	a := testTx(0, funding)
+	node.AddTx(a)
+	tip := node.Mine(a.Txid).Hash
+	node.Fail("getblock", fmt.Errorf("boom"))
+	tick(c)
+	if blockHashes(c)[tip] {
+		t.Fatal("failed block is parsed")
+	}
+	// same tip, skipped by the hash check. new tip brings it back
+	node.Mine()
+	tick(c)
+	if !blockHashes(c)[tip] {
+		t.Fatal("failed block is not parsed on the next tip")
+	}
+}
+
+// stored blocks are indexed again after the restart, not skipped
+func TestBlockWorkersRestart(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	a := testTx(0, funding)
+	node.AddTx(a)
+	mined := node.Mine(a.Txid).Hash
+	s := smap.New()
+	c := newTestCoreOn(t, testConfig(), node, s)
+	tick(c)
+	if len(c.GetBlocks()) != 3 {
+		t.Fatalf("blocks %d, want 3", len(c.GetBlocks()))
+	}
+
+	// new core over the same storage, node has nothing new
+	calls := node.Calls("getblock")
+	c = newTestCoreOn(t, testConfig(), node, s)
+	tick(c)
+	hashes := blockHashes(c)
+	if len(hashes) != 3 || !hashes[mined] {
+		t.Fatalf("blocks after restart %v", hashes)
+	}
+	if n := node.Calls("getblock"); n != calls {
+		t.Fatalf("getblock called %d times for the stored blocks", n-calls)
+	}
+	stats, _ := c.GetBlock(mined)
+	if stats == nil || !stats.IsComplete() || stats.Fee != 900_000 {
+		t.Fatalf("mined block stats %+v", stats)
+	}
+
+	// reorg of the block stored before the restart is detected
+	node.Reorg(1)
+	node.Mine()
+	tick(c)
+	hashes = blockHashes(c)
+	if len(hashes) != 3 || hashes[mined] {
+		t.Fatalf("blocks after reorg %v", hashes)
+	}
+	if exists, _ := s.BlockExists(mined); exists {
+		t.Fatal("stale block is in storage")
+	}
+}
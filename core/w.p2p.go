@@ -0,0 +1,70 @@
+package core
+
+import (
+	"github.com/1F47E/go-feesh/logger"
+	"github.com/1F47E/go-feesh/p2p"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// burst of txs delivered by the peers
+const p2pQueueSize = 10_000
+
+// txs and blocks announced by the peers over the bitcoin wire protocol.
+// txs come with the full data, only the pool entry is requested from the node
+func (c *Core) workerP2P() {
+	log := logger.Log.WithField("context", "[workerP2P]")
+	log.Info("started")
+	defer func() {
+		log.Info("stopped")
+	}()
+	params, err := p2p.Params(c.Cfg.P2PNetwork)
+	if err != nil {
+		log.Errorf("error on p2p network: %v\n", err)
+		return
+	}
+	l := p2p.NewListener(params, c.Cfg.P2PPeers)
+	// converted off the peer read loops
+	txs := make(chan *wire.MsgTx, p2pQueueSize)
+	l.OnTx = func(t *wire.MsgTx, seen p2p.Seen) {
+		select {
+		case txs <- t:
+		default:
+			log.Warnf("tx queue is full, dropped %s\n", t.TxHash())
+		}
+	}
+	go func() {
+		for {
+			select {
+			case <-c.ctx.Done():
+				return
+			case t := <-txs:
+				btx := p2p.ToTransaction(t, params)
+				c.pushTxAdded(btx.Txid, btx)
+			}
+		}
+	}()
+	l.OnBlock = func(hash string) {
+		log.Debugf("block announced %s\n", hash)
+		c.pushBlock()
+	}
+	l.OnState = c.pushState
+	c.mu.Lock()
+	c.p2p = l
+	c.mu.Unlock()
+	l.Run(c.ctx)
+}
+
+// when and where the tx was announced first, p2p mode only
+func (c *Core) GetTxFirstSeen(txid string) (*p2p.Seen, bool) {
+	c.mu.Lock()
+	l := c.p2p
+	c.mu.Unlock()
+	if l == nil {
+		return nil, false
+	}
+	seen, ok := l.FirstSeen(txid)
+	if !ok {
+		return nil, false
+	}
+	return &seen, true
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	"github.com/1F47E/go-feesh/p2p"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// txs delivered by the peer get to the pool copy and the parsed pool
+func TestP2PTxs(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	peer, err := p2p.NewFakePeer("127.0.0.1:0", params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	node := client.NewFakeNode()
+	funding := testFunding(node, 5)
+	fundingHash, _ := chainhash.NewHashFromStr(funding)
+	cfg := testConfig()
+	cfg.P2PPeers = []string{peer.Addr()}
+	cfg.P2PNetwork = "regtest"
+	c := newTestCore(t, cfg, node)
+
+	go c.workerP2P()
+	go c.workerPush()
+	go c.workerTxParser(1)
+	waitFor(t, "peer connected", c.pushActive)
+
+	msgs := make([]*wire.MsgTx, 5)
+	for i := range msgs {
+		m := wire.NewMsgTx(2)
+		m.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingHash, uint32(i)), nil, nil))
+		m.AddTxOut(wire.NewTxOut(100_000, []byte{0x51}))
+		node.AddPoolTx(p2p.ToTransaction(m, params), txpool.TxPool{Fee: 900_000, Vsize: uint32(m.SerializeSize()), Time: time.Now().Unix()})
+		msgs[i] = m
+	}
+	for _, m := range msgs {
+		peer.AnnounceTx(m)
+	}
+	waitFor(t, "delivered txs in the pool", func() bool {
+		c.sortPool()
+		return c.GetPoolSize() == len(msgs)
+	})
+	for _, m := range msgs {
+		if seen, ok := c.GetTxFirstSeen(m.TxHash().String()); !ok || seen.Peer != peer.Addr() {
+			t.Fatalf("first seen %+v, %v", seen, ok)
+		}
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"sort"
 	"time"
 
-	"github.com/1F47E/go-feesh/config"
+	"github.com/1F47E/go-feesh/client"
 	"github.com/1F47E/go-feesh/entity/btc/txpool"
 	mtx "github.com/1F47E/go-feesh/entity/models/tx"
 	"github.com/1F47E/go-feesh/logger"
@@ -15,6 +15,20 @@ import (
 // last bucket is 500+
 var buckets = []uint{2, 3, 4, 5, 6, 8, 10, 15, 25, 35, 50, 70, 85, 100, 125, 150, 200, 250, 300, 350, 400, 450, 499, 500}
 
+// index of the fee bucket for the fee rate
+func bucketIndex(feeB uint) int {
+	// fix max fee
+	if feeB > 500 {
+		feeB = 500
+	}
+	for i, b := range buckets {
+		if feeB <= b {
+			return i
+		}
+	}
+	return 0
+}
+
 // var poolSizeHistoryTimeFrame = 1 * time.Minute
 var poolSizeHistoryTimeFrame = 5 * time.Second
 var poolSizeHistoryLimit = 40
@@ -28,73 +42,121 @@ func (c *Core) workerPoolPuller(period time.Duration) {
 		ticker.Stop()
 	}()
 
+	reconcile := time.Duration(c.Cfg.ReconcilePeriod) * time.Second
+	var lastPoll time.Time
 	for {
 		select {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
-			// get the block height
-			info, err := c.cli.GetInfo()
-			if err != nil {
-				log.Errorf("error on getinfo: %v\n", err)
+			// updates are pushed, poll only to reconcile
+			if c.pushActive() && time.Since(lastPoll) < reconcile {
 				continue
 			}
+		case <-c.poolPullCh:
+			// pushed by the node, do not wait for the tick
+		}
+		lastPoll = time.Now()
+		txids, inPool := c.pullPool()
+		// send new txs to parser
+		for _, txid := range txids {
+			c.parserJobCh <- txid
+		}
+		// after the new txs are sent, replacement of the removed tx can be among them
+		if inPool != nil {
+			c.rbf.Prune(inPool)
+		}
+	}
+}
 
-			if c.height != info.Blocks {
-				c.height = info.Blocks
-				log.Debugf("new block height: %d\n", info.Blocks)
-			}
+// single poll of the node pool, updates the pool copy.
+// returns the new txs to parse and the pool txids, nil if nothing changed
+func (c *Core) pullPool() ([]string, map[string]bool) {
+	log := logger.Log.WithField("context", "[workerPoolPuller]")
+	// get the block height
+	info, err := c.cli.GetInfo(c.ctx)
+	if err != nil {
+		if client.IsUnavailable(err) {
+			log.Warnf("node unavailable: %v\n", err)
+			return nil, nil
+		}
+		log.Errorf("error on getinfo: %v\n", err)
+		return nil, nil
+	}
 
-			// get ordered list of pool tsx. new first
-			poolTxs, err := c.cli.RawMempool()
-			if err != nil {
-				log.Errorf("error on rawmempool: %v\n", err)
-				continue
-			}
-			if len(poolTxs) == 0 {
-				continue
-			}
+	if c.GetHeight() != info.Blocks {
+		c.setHeight(info.Blocks)
+		log.Debugf("new block height: %d\n", info.Blocks)
+	}
 
-			// check if we have new txs
-			hasNew := false
-			for _, tx := range poolTxs {
-				if _, ok := c.poolCopyMap[tx.Txid]; !ok {
-					hasNew = true
-					break
-				}
-			}
-			if !hasNew {
-				continue
-			}
-			log.Debugf("got some new txs\n")
-			log.Warnf("new pool size: %d\n", len(poolTxs))
-
-			// copy pool txs mem for later reference what pool have
-			c.mu.Lock()
-			c.poolCopy = make([]txpool.TxPool, len(poolTxs))
-			c.poolCopyMap = make(map[string]txpool.TxPool)
-			for i, tx := range poolTxs {
-				c.poolCopy[i] = tx
-				c.poolCopyMap[tx.Txid] = tx
-			}
-			c.mu.Unlock()
-
-			// send new txs to parser
-			for _, tx := range poolTxs {
-				// skip if already parsed
-				exists, err := c.storage.TxGet(tx.Txid)
-				if err != nil {
-					log.Errorf("error on txget: %v\n", err)
-					continue
-				}
-				if exists != nil {
-					continue
-				}
-				log.Debugf("new pool tx, sending to parser: %+v\n", tx)
-				c.parserJobCh <- tx.Txid
-			}
+	// get ordered list of pool tsx. new first
+	poolTxs, err := c.cli.RawMempool(c.ctx)
+	if err != nil {
+		log.Errorf("error on rawmempool: %v\n", err)
+		return nil, nil
+	}
+
+	// check if we have new txs
+	// and diff with the previous copy, removal reason is resolved later.
+	// the copy is updated by the push sources too
+	inPool := make(map[string]bool, len(poolTxs))
+	added := make([]txpool.TxPool, 0)
+	removed := make([]txpool.TxPool, 0)
+	c.mu.Lock()
+	for _, tx := range poolTxs {
+		inPool[tx.Txid] = true
+		if _, ok := c.poolCopyMap[tx.Txid]; !ok {
+			added = append(added, tx)
+		}
+	}
+	for txid, tx := range c.poolCopyMap {
+		if !inPool[txid] {
+			removed = append(removed, tx)
+		}
+	}
+	c.mu.Unlock()
+	hasNew := len(added) > 0
+	for _, tx := range added {
+		c.removals.Back(tx.Txid)
+		c.confirmations.Seen(tx.Txid, time.Unix(tx.Time, 0), info.Blocks)
+	}
+	if !hasNew && len(removed) == 0 {
+		return nil, nil
+	}
+	log.Debugf("got some new txs\n")
+	log.Warnf("new pool size: %d\n", len(poolTxs))
+
+	// copy pool txs mem for later reference what pool have, ordered by the sorter
+	c.mu.Lock()
+	c.poolCopyMap = make(map[string]txpool.TxPool, len(poolTxs))
+	for _, tx := range poolTxs {
+		c.poolCopyMap[tx.Txid] = tx
+	}
+	if len(removed) > 0 {
+		log.Debugf("txs removed from pool: %d\n", len(removed))
+		c.removals.Removed(removed, c.poolCopyMap)
+	}
+	c.mu.Unlock()
+
+	txids := make([]string, len(poolTxs))
+	for i, tx := range poolTxs {
+		txids[i] = tx.Txid
+	}
+	stored, err := c.storage.TxGetMany(txids)
+	if err != nil {
+		log.Errorf("error on txget: %v\n", err)
+		return nil, nil
+	}
+	parse := make([]string, 0)
+	for i, tx := range poolTxs {
+		// skip if already parsed
+		if stored[i] != nil {
+			continue
 		}
+		log.Debugf("new pool tx, sending to parser: %+v\n", tx)
+		parse = append(parse, tx.Txid)
 	}
+	return parse, inPool
 }
 
 func (c *Core) workerPoolSizeHistory(period time.Duration) {
@@ -135,138 +197,160 @@ func (c *Core) workerPoolSorter(period time.Duration) {
 		case <-c.ctx.Done():
 			return
 		case <-ticker.C:
-			// construct pool slice for API access
-			// get pool copy, merge it with parsed tx
-			// order by time
-
-			// TODO: create another copy sorted by fee, calc fee buckets
-			now := time.Now()
-
-			res := make([]mtx.Tx, 0)
-			c.mu.Lock()
-			// collect parsed txs based on pool copy
-			// also count totals
-			var amount, weight uint64
-			var totalFee1000 float64
-			feeBuckets := make([]uint, len(buckets))
-
-			for _, tx := range c.poolCopy {
-				// get parsed tx
-				parsedTx, err := c.storage.TxGet(tx.Txid)
-				if err != nil {
-					log.Errorf("error on txget: %v\n", err)
-					continue
-				}
-				if parsedTx == nil {
-					continue
-				}
-				// fix time
-				parsedTx.Time = time.Unix(int64(tx.Time), 0)
-
-				res = append(res, *parsedTx)
-
-				// totals
-				amount += parsedTx.AmountOut
-
-				// because total fee in sat will overflow uint64, storing in 1000 sat with approx precision
-				txFee1000 := float64(parsedTx.Fee) / 1000
-				if txFee1000 > 10 {
-					log.Warnf("tx fee is too big: %f, %+v\n", txFee1000, parsedTx)
-				}
-				totalFee1000 += txFee1000
-				weight += uint64(parsedTx.Weight)
-
-				// count fee buckets
-				feeB := parsedTx.FeePerByte()
-				// fix max fee
-				if feeB > 500 {
-					feeB = 500
-				}
-				bucket := 0
-				for i, b := range buckets {
-					if feeB <= b {
-						bucket = i
-						break
-					}
-				}
-
-				feeBuckets[bucket]++
-			}
-			// log.Warnf("fee buckets: (%d) %v\n", len(feeBuckets), feeBuckets)
-
-			// sort by fee - check if tx will fit in the next block
-			sort.Slice(res, func(i, j int) bool {
-				return res[i].Fee > res[j].Fee
-			})
-			var totalSize uint32
-			for i := range res {
-				if res[i].Fee == 0 {
-					continue
-				}
-				if totalSize+res[i].Size > config.BLOCK_SIZE {
-					break
-				}
-				totalSize += res[i].Size
-				res[i].Fits = true
-			}
+			c.sortPool()
+		}
+	}
+}
 
-			// sort by time
-			sort.Slice(res, func(i, j int) bool {
-				if !res[i].Time.Equal(res[j].Time) {
-					return res[i].Time.After(res[j].Time)
-				}
-				// sometimes time can be equal, sort by Hash
-				return res[i].Hash < res[j].Hash
-			})
-			prevPoolCnt := len(c.poolSorted)
-			c.poolSorted = res
-			c.totalAmount = amount
-			c.poolFeeTotal = uint64(totalFee1000)
-			c.totalSize = uint64(totalSize)
-
-			// TODO: fee estimator
-
-			// Calc fee buckets
-			bucketsMap := make(map[uint]uint)
-			for i, b := range buckets {
-				bucketsMap[b] = feeBuckets[i]
-			}
-			c.feeBucketsMap = bucketsMap
-			c.feeBuckets = feeBuckets
+// construct pool slice for API access
+// get pool copy, merge it with parsed tx
+// order by time
+func (c *Core) sortPool() {
+	log := logger.Log.WithField("context", "[workerPoolSorter]")
+	// TODO: create another copy sorted by fee, calc fee buckets
+	now := time.Now()
+
+	res := make([]mtx.Tx, 0)
+	c.mu.Lock()
+	// collect parsed txs based on pool copy
+	// also count totals
+	var amount, weight uint64
+	var totalFee1000 float64
+	feeBuckets := make([]uint, len(buckets))
+
+	// pool copy ordered once per tick, new first
+	poolCopy := make([]txpool.TxPool, 0, len(c.poolCopyMap))
+	for _, tx := range c.poolCopyMap {
+		poolCopy = append(poolCopy, tx)
+	}
+	sort.Slice(poolCopy, func(i, j int) bool {
+		if poolCopy[i].Time != poolCopy[j].Time {
+			return poolCopy[i].Time > poolCopy[j].Time
+		}
+		return poolCopy[i].Txid < poolCopy[j].Txid
+	})
 
-			c.mu.Unlock()
-			if prevPoolCnt != len(res) {
-				log.Debugf("pool sorted, took: %v\n", time.Since(now))
-				log.Debugf("total txs: %d\n", len(res))
-			}
+	// get parsed txs
+	txids := make([]string, len(poolCopy))
+	for i, tx := range poolCopy {
+		txids[i] = tx.Txid
+	}
+	parsedTxs, err := c.storage.TxGetMany(txids)
+	if err != nil {
+		log.Errorf("error on txget: %v\n", err)
+		parsedTxs = make([]*mtx.Tx, len(txids))
+	}
+	for i, tx := range poolCopy {
+		parsedTx := parsedTxs[i]
+		if parsedTx == nil {
+			continue
+		}
+		// fix time
+		parsedTx.Time = time.Unix(int64(tx.Time), 0)
 
-			var feeAvg float64
-			if len(res) > 0 {
-				feeAvg = float64(totalFee1000) / float64(totalSize)
-			}
-			c.poolFeeAvg = uint64(feeAvg * 1000)
-			// fee butkets
-			// TODO: move size to const
-			var feeBucketsArr [24]uint
-			copy(feeBucketsArr[:], feeBuckets)
+		res = append(res, *parsedTx)
 
-			var poolSizeHistory [20]uint
-			copy(poolSizeHistory[:], c.poolSizeHistory)
+		// totals
+		amount += parsedTx.AmountOut
 
-			// send websocket update
-			msg := notificator.Msg{
-				Height:          c.height,
-				PoolSize:        len(res),
-				PoolSizeHistory: poolSizeHistory,
-				TotalFee:        int(c.poolFeeTotal),
-				AvgFee:          int(c.poolFeeAvg),
-				Amount:          int(amount),
-				Size:            int(totalSize),
-				FeeBuckets:      feeBucketsArr,
-			}
-			go c.nofity(msg)
+		// because total fee in sat will overflow uint64, storing in 1000 sat with approx precision
+		txFee1000 := float64(parsedTx.Fee) / 1000
+		if txFee1000 > 10 {
+			log.Warnf("tx fee is too big: %f, %+v\n", txFee1000, parsedTx)
+		}
+		totalFee1000 += txFee1000
+		weight += uint64(parsedTx.Weight)
+	}
+
+	// in-pool dependency graph for CPFP packages
+	graph := newTxGraph(res)
+
+	// project next blocks by ancestor fee rate - check if tx will fit in the next block
+	projected, projectedRates := projectBlocks(res, graph.parents, projectedBlocksCount)
+	graph.applyPackages(res, projectedRates)
+	fits := make(map[string]bool)
+	if len(projected) > 0 {
+		for _, txid := range projected[0].Txids {
+			fits[txid] = true
 		}
 	}
+
+	// count fee buckets by effective fee rate
+	for _, tx := range res {
+		feeBuckets[bucketIndex(uint(tx.FeeRate()))]++
+	}
+	// log.Warnf("fee buckets: (%d) %v\n", len(feeBuckets), feeBuckets)
+
+	var totalSize uint32
+	for i := range res {
+		if !fits[res[i].Hash] {
+			continue
+		}
+		totalSize += res[i].Size
+		res[i].Fits = true
+	}
+
+	// sort by time
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Time.Equal(res[j].Time) {
+			return res[i].Time.After(res[j].Time)
+		}
+		// sometimes time can be equal, sort by Hash
+		return res[i].Hash < res[j].Hash
+	})
+	prevPoolCnt := len(c.poolSorted)
+	c.poolSorted = res
+	c.totalAmount = amount
+	c.poolFeeTotal = uint64(totalFee1000)
+	c.totalSize = uint64(totalSize)
+	c.projectedBlocks = projected
+	height := c.height
+	if len(projected) > 0 {
+		c.saveTemplate(height, projected[0].Txids)
+	}
+
+	fees := c.feeEstimator.Update(res)
+
+	// Calc fee buckets
+	bucketsMap := make(map[uint]uint)
+	for i, b := range buckets {
+		bucketsMap[b] = feeBuckets[i]
+	}
+	c.feeBucketsMap = bucketsMap
+	c.feeBuckets = feeBuckets
+
+	c.mu.Unlock()
+	if prevPoolCnt != len(res) {
+		log.Debugf("pool sorted, took: %v\n", time.Since(now))
+		log.Debugf("total txs: %d\n", len(res))
+	}
+
+	var feeAvg float64
+	if len(res) > 0 {
+		feeAvg = float64(totalFee1000) / float64(totalSize)
+	}
+	c.poolFeeAvg = uint64(feeAvg * 1000)
+	// fee butkets
+	// TODO: move size to const
+	var feeBucketsArr [24]uint
+	copy(feeBucketsArr[:], feeBuckets)
+
+	var poolSizeHistory [20]uint
+	copy(poolSizeHistory[:], c.poolSizeHistory)
+
+	// send websocket update
+	msg := notificator.Msg{
+		Height:          height,
+		PoolSize:        len(res),
+		PoolSizeHistory: poolSizeHistory,
+		TotalFee:        int(c.poolFeeTotal),
+		AvgFee:          int(c.poolFeeAvg),
+		Amount:          int(amount),
+		Size:            int(totalSize),
+		FeeBuckets:      feeBucketsArr,
+		Fees:            fees,
+	}
+	go c.nofity(msg)
 }
 
 func (c *Core) workerPoolDebug(period time.Duration) {
@@ -316,3 +400,12 @@ func (c *Core) nofity(msg notificator.Msg) {
 		logger.Log.Error("timeout on sending websocket message\n")
 	}
 }
+
+// send websocket event
+func (c *Core) emit(evType string, data interface{}) {
+	select {
+	case c.eventsCh <- notificator.Event{Type: evType, Data: data}:
+	case <-time.After(time.Second * 5):
+		logger.Log.Errorf("timeout on sending websocket event %s\n", evType)
+	}
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/txpool"
+	mremoval "github.com/1F47E/go-feesh/entity/models/removal"
+)
+
+func TestPoolWorkers(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+
+	a, b, d := testTx(0, funding), testTx(1, funding), testTx(2, funding)
+	// spends the same output as b
+	rep := testTx(1, funding)
+	rep.Txid = fmt.Sprintf("%064x", 2_000_001)
+	node.Script(
+		func(f *client.FakeNode) {
+			f.AddPoolTx(a, txpool.TxPool{Fee: 1000, Vsize: 200})
+			f.AddPoolTx(b, txpool.TxPool{Fee: 500, Vsize: 200})
+		},
+		func(f *client.FakeNode) {
+			f.RemovePoolTx(b.Txid)
+			f.AddPoolTx(rep, txpool.TxPool{Fee: 3000, Vsize: 200})
+		},
+		func(f *client.FakeNode) {
+			f.AddPoolTx(d, txpool.TxPool{Fee: 800, Vsize: 200})
+			f.Mine(a.Txid)
+		},
+	)
+
+	step(t, node, c)
+	if pool := poolTxids(c); len(pool) != 2 || !pool[a.Txid] || !pool[b.Txid] {
+		t.Fatalf("pool %v, want a and b", pool)
+	}
+	parsed, _ := c.storage.TxGet(a.Txid)
+	if parsed == nil || parsed.Fee != 1000 || parsed.AmountIn != parsed.AmountOut+1000 {
+		t.Fatalf("parsed a: %+v", parsed)
+	}
+	if c.GetFeeTotal() != 1 {
+		t.Errorf("fee total %d (1000 sat), want 1", c.GetFeeTotal())
+	}
+
+	// replaced
+	step(t, node, c)
+	if pool := poolTxids(c); len(pool) != 2 || !pool[a.Txid] || !pool[rep.Txid] {
+		t.Fatalf("pool %v, want a and replacement", pool)
+	}
+	reps := c.GetReplacements(10)
+	if len(reps) != 1 || reps[0].Txid != b.Txid || reps[0].ReplacedBy != rep.Txid || reps[0].FeeDelta != 2500 {
+		t.Fatalf("replacements %+v", reps)
+	}
+	c.removals.Resolve(c.rbf)
+	if rm, ok := c.GetTxRemoval(b.Txid); !ok || rm.Reason != mremoval.ReasonReplaced || rm.ReplacedBy != rep.Txid {
+		t.Fatalf("removal of b: %+v %v", rm, ok)
+	}
+
+	// mined
+	step(t, node, c)
+	if pool := poolTxids(c); len(pool) != 2 || !pool[d.Txid] || !pool[rep.Txid] {
+		t.Fatalf("pool %v, want d and replacement", pool)
+	}
+	if c.GetHeight() != 3 {
+		t.Errorf("height %d, want 3", c.GetHeight())
+	}
+	c.removals.Resolve(c.rbf)
+	if rm, ok := c.GetTxRemoval(a.Txid); !ok || rm.Reason != mremoval.ReasonConfirmed || rm.BlockHeight != 3 {
+		t.Fatalf("removal of a: %+v %v", rm, ok)
+	}
+	if node.Next() {
+		t.Fatal("steps left")
+	}
+}
+
+// unavailable node keeps the last pool
+func TestPoolPullUnavailable(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+	node.AddPoolTx(testTx(0, funding), txpool.TxPool{Fee: 1000, Vsize: 200})
+	tick(c)
+
+	node.Fail("getinfo", &client.TransportError{Err: fmt.Errorf("connection refused")})
+	node.AddPoolTx(testTx(1, funding), txpool.TxPool{Fee: 1000, Vsize: 200})
+	if txids, inPool := c.pullPool(); txids != nil || inPool != nil {
+		t.Fatalf("pulled %v from the unavailable node", txids)
+	}
+	c.sortPool()
+	if c.GetPoolSize() != 1 {
+		t.Fatalf("pool size %d, want 1", c.GetPoolSize())
+	}
+	tick(c)
+	if c.GetPoolSize() != 2 {
+		t.Fatalf("pool size %d after recovery, want 2", c.GetPoolSize())
+	}
+}
+
+// last txs mined, the pool is empty but the removals are still diffed
+func TestPoolPullEmpty(t *testing.T) {
+	node := client.NewFakeNode()
+	funding := testFunding(node, 10)
+	c := newTestCore(t, testConfig(), node)
+	a, b := testTx(0, funding), testTx(1, funding)
+	node.AddPoolTx(a, txpool.TxPool{Fee: 1000, Vsize: 200})
+	node.AddPoolTx(b, txpool.TxPool{Fee: 1000, Vsize: 200})
+	tick(c)
+	if c.GetPoolSize() != 2 {
+		t.Fatalf("pool size %d, want 2", c.GetPoolSize())
+	}
+
+	node.Mine(a.Txid, b.Txid)
+	tick(c)
+	if c.GetPoolSize() != 0 || len(poolTxids(c)) != 0 {
+		t.Fatalf("pool size %d after the pool is empty", c.GetPoolSize())
+	}
+	c.removals.Resolve(c.rbf)
+	if n := c.GetRemovalCounters()[mremoval.ReasonConfirmed]; n != 2 {
+		t.Fatalf("confirmed removals %d, want 2", n)
+	}
+}
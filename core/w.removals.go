@@ -0,0 +1,29 @@
+package core
+
+import (
+	"time"
+
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// classify txs removed from the pool once blocks and replacements are known
+func (c *Core) workerRemovals(period time.Duration) {
+	log := logger.Log.WithField("context", "[workerRemovals]")
+	log.Info("started")
+	ticker := time.NewTicker(period)
+	defer func() {
+		log.Info("stopped")
+		ticker.Stop()
+	}()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, rm := range c.removals.Resolve(c.rbf) {
+				log.Debugf("tx %s removed from pool: %s\n", rm.Txid, rm.Reason)
+				c.confirmations.Add(rm)
+			}
+		}
+	}
+}
@@ -4,10 +4,22 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
 	mtx "github.com/1F47E/go-feesh/entity/models/tx"
 	"github.com/1F47E/go-feesh/logger"
+	"github.com/1F47E/go-feesh/notificator"
 )
 
+// how long to wait for more jobs to fill the batch
+var txBatchWait = 50 * time.Millisecond
+
+type txResult struct {
+	tx     *mtx.Tx
+	inPool bool
+	err    error
+}
+
 // log carefull, there can be a lot of workers
 func (c *Core) workerTxParser(n int) {
 	log := logger.Log.WithField("context", fmt.Sprintf("[workerTxParser] #%d", n))
@@ -20,84 +32,180 @@ func (c *Core) workerTxParser(n int) {
 		case <-c.ctx.Done():
 			return
 		case txid := <-c.parserJobCh:
-			var err error
+			c.parseBatch(c.collectTxBatch(txid))
+		}
+	}
+}
+
+// parse and store the txs not parsed yet
+func (c *Core) parseBatch(batch []string) {
+	log := logger.Log.WithField("context", "[workerTxParser]")
+	// skip if already parsed with all the amounts
+	// pool txs are sent again by the block parser once mined
+	stored, err := c.storage.TxGetMany(batch)
+	if err != nil {
+		log.Errorf("error on txget: %v\n", err)
+		return
+	}
+	txids := make([]string, 0, len(batch))
+	for i, txid := range batch {
+		if stored[i] != nil && stored[i].IsComplete() {
+			continue
+		}
+		txids = append(txids, txid)
+	}
+	if len(txids) == 0 {
+		return
+	}
 
-			// parse tx
-			// log.Log.Debugf("%s parsing tx: %s\n", name, txid)
-			btx, err := c.cli.TransactionGet(txid)
-			if err != nil {
-				log.Errorf("error on getrawtransaction %s: %v\n", txid, err)
+	parsed := make([]txResult, 0, len(txids))
+	for i, res := range c.parseTxs(txids) {
+		if res.err != nil {
+			// pool tx can be gone already
+			if client.IsNotFound(res.err) {
+				log.Debugf("tx %s not found\n", txids[i])
 				continue
 			}
-			// log.Log.Debugf("%s parsed tx txid: %s\n", name, txid)
+			log.Errorf("error on parsing tx %s: %v\n", txids[i], res.err)
+			continue
+		}
+		parsed = append(parsed, res)
+	}
+	c.saveTxs(parsed)
+}
+
+// store the parsed tx, detect replacements of the pool txs
+func (c *Core) saveTx(tx *mtx.Tx, inPool bool) {
+	c.saveTxs([]txResult{{tx: tx, inPool: inPool}})
+}
+
+// parsed txs are stored with a single write
+func (c *Core) saveTxs(res []txResult) {
+	if len(res) == 0 {
+		return
+	}
+	txs := make([]mtx.Tx, len(res))
+	for i, r := range res {
+		txs[i] = *r.tx
+	}
+	if err := c.storage.TxAddMany(txs); err != nil {
+		logger.Log.Errorf("error on saving txs: %v\n", err)
+	}
+	for _, r := range res {
+		if !r.inPool {
+			continue
+		}
+		for _, rep := range c.rbf.Track(*r.tx) {
+			logger.Log.WithField("context", "[rbf]").Infof("tx %s replaced by %s, fee delta %d\n", rep.Txid, rep.ReplacedBy, rep.FeeDelta)
+			go c.emit(notificator.EventRbf, rep)
+		}
+	}
+}
 
-			// NOTE: this is buggy, need to rewrite all of this.
+// the first job and the ones that come shortly after, up to the batch size
+func (c *Core) collectTxBatch(first string) []string {
+	batch := []string{first}
+	timer := time.NewTimer(txBatchWait)
+	defer timer.Stop()
+	for len(batch) < c.Cfg.RpcBatchSize {
+		select {
+		case <-c.ctx.Done():
+			return batch
+		case <-timer.C:
+			return batch
+		case txid := <-c.parserJobCh:
+			batch = append(batch, txid)
+		}
+	}
+	return batch
+}
 
-			// TODO: implement after proper tx storage to store all in and aout amounts properly
+// get txs from the node with a batch request and remap to model with all the amounts.
+// results are in the order of txids
+func (c *Core) parseTxs(txids []string) []txResult {
+	res := make([]txResult, len(txids))
+	btxs, errs, err := c.cli.TransactionGetMany(c.ctx, txids)
+	if err != nil {
+		for i := range res {
+			res[i].err = err
+		}
+		return res
+	}
 
-			// Vin
-			// in order to calc fee we need input amounts.
-			// to get them we have to parse Vin tx amounts
-			// find out amount from vin tx matching by vout index
-			// var in uint64
-			// for _, vin := range btx.Vin {
-			// 	// mined
-			// 	if vin.Coinbase != "" {
-			// 		log.Warnf("got coinbase tx: %s\n", txid)
-			// 		continue
-			// 	}
-			// 	txIn, err := c.cli.TransactionGet(vin.Txid)
-			// 	if err != nil {
-			// 		log.Errorf("error getting vin tx: %v\n", err)
-			// 		break
-			// 	}
-			// 	in = txIn.GetTotalOut()
-			//
-			// 	// remap raw tx to model and save
-			// 	// TODO: make constructor
-			// 	mtxIn := mtx.Tx{
-			// 		Hash:      vin.Txid,
-			// 		Time:      time.Unix(int64(btx.Time), 0),
-			// 		Size:      uint32(btx.Size),
-			// 		Weight:    uint32(btx.Weight),
-			// 		AmountOut: btx.GetTotalOut(),
-			// 		AmountIn:  0,
-			// 	}
-			// 	_ = c.storage.TxAdd(mtxIn)
-			// }
-			// if in <= 0 {
-			// 	if in == 0 {
-			// 		log.Errorf("no input amount, skipping tx: %s\n", txid)
-			// 	}
-			// 	// -1 is coinbase, no need to log error
-			// 	continue
-			// }
+	// block txs need the prevouts, fetch them all at once
+	// outputs of this batch are added first, txs often spend the ones in the same block
+	resolve := make([]*tx.Transaction, 0)
+	for _, txid := range txids {
+		btx, ok := btxs[txid]
+		if !ok {
+			continue
+		}
+		c.prevouts.Add(txid, btx.GetOutValues())
+		c.mu.Lock()
+		_, inPool := c.poolCopyMap[txid]
+		c.mu.Unlock()
+		if !inPool && !btx.IsCoinbase() {
+			resolve = append(resolve, btx)
+		}
+	}
+	if len(resolve) > 0 {
+		if err := c.prevouts.Prefetch(c.ctx, resolve); err != nil {
+			logger.Log.Errorf("error on prevouts prefetch: %v\n", err)
+		}
+	}
 
-			// remap raw tx to model
-			// out := btx.GetTotalOut()
-			// fee := uint64(in) - out
-			tx := mtx.Tx{
-				Hash: txid,
-				// NOTE: mempool tx dont have time in rawtransaction
-				// only in custom ramempool tx we have pool time
-				Time:      time.Unix(int64(btx.Time), 0),
-				Size:      uint32(btx.Size),
-				Weight:    uint32(btx.Weight),
-				AmountOut: btx.GetTotalOut(),
-				// AmountIn:  uint64(in),
-				// Fee:       fee,
-			}
+	for i, txid := range txids {
+		btx, ok := btxs[txid]
+		if !ok {
+			res[i].err = fmt.Errorf("error on getrawtransaction: %w", errs[txid])
+			continue
+		}
+		res[i].tx, res[i].inPool, res[i].err = c.txFromRaw(txid, btx)
+	}
+	return res
+}
 
-			// get pool tx to use fee already calculated by node
-			c.mu.Lock()
-			ptx := c.poolCopyMap[txid]
-			c.mu.Unlock()
-			if ptx.Txid != "" {
-				tx.Fee = ptx.Fee
-				log.Debugf("applying fee from pool tx %s - fee %d\n", txid, ptx.Fee)
-			}
+// fee of the pool tx is taken from the pool, for the block tx its calculated from prevouts
+func (c *Core) txFromRaw(txid string, btx *tx.Transaction) (*mtx.Tx, bool, error) {
+	// remap raw tx to model
+	tx := &mtx.Tx{
+		Hash: txid,
+		// NOTE: mempool tx dont have time in rawtransaction
+		// only in custom ramempool tx we have pool time
+		Time:      time.Unix(int64(btx.Time), 0),
+		Size:      uint32(btx.Size),
+		Weight:    uint32(btx.Weight),
+		AmountOut: btx.GetTotalOut(),
+		Coinbase:  btx.IsCoinbase(),
+		Segwit:    btx.IsSegwit(),
+		Taproot:   btx.IsTaproot(),
+		Depends:   btx.GetInputTxids(),
+		Spends:    btx.GetOutpoints(),
+	}
 
-			_ = c.storage.TxAdd(tx)
+	// get pool tx to use fee already calculated by node
+	c.mu.Lock()
+	ptx := c.poolCopyMap[txid]
+	c.mu.Unlock()
+	inPool := ptx.Txid != ""
+	switch {
+	case tx.Coinbase:
+		// mined, no inputs
+		tx.Pool = c.miners.Identify(btx)
+	case inPool:
+		tx.Fee = ptx.Fee
+		tx.AmountIn = tx.AmountOut + tx.Fee
+	default:
+		// block tx, in order to calc fee we need input amounts from the prevouts
+		in, err := c.prevouts.AmountIn(c.ctx, btx)
+		if err != nil {
+			return nil, false, fmt.Errorf("error on getting inputs: %w", err)
+		}
+		if in < tx.AmountOut {
+			return nil, false, fmt.Errorf("inputs are less than outputs: %d < %d", in, tx.AmountOut)
 		}
+		tx.AmountIn = in
+		tx.Fee = in - tx.AmountOut
 	}
+	return tx, inPool, nil
 }
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"github.com/1F47E/go-feesh/client"
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// new pool txs and blocks pushed by btcd websocket.
+// btcd has no removal notifications, those come from the reconciliation
+func (c *Core) workerWs() {
+	log := logger.Log.WithField("context", "[workerWs]")
+	log.Info("started")
+	defer func() {
+		log.Info("stopped")
+	}()
+	ws, err := client.NewWsClient(c.Cfg.WsHost, c.Cfg.RpcUser, c.Cfg.RpcPass)
+	if err != nil {
+		log.Errorf("error on websocket client: %v\n", err)
+		return
+	}
+	ws.Run(c.ctx, client.WsHandlers{
+		OnTxAccepted: func(t *tx.Transaction) {
+			c.pushTxAdded(t.Txid, t)
+		},
+		OnBlockConnected: func(hash string, height int) {
+			log.Debugf("block connected %d %s\n", height, hash)
+			c.pushBlock()
+		},
+		OnBlockDisconnected: func(hash string, height int) {
+			log.Warnf("block disconnected %d %s\n", height, hash)
+			c.pushBlock()
+		},
+		OnState: c.pushState,
+	})
+}
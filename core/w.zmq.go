@@ -0,0 +1,51 @@
+package core
+
+import (
+	"github.com/1F47E/go-feesh/logger"
+	"github.com/1F47E/go-feesh/zmq"
+)
+
+// new and removed pool txs and connected blocks pushed by bitcoin core
+func (c *Core) workerZmq() {
+	log := logger.Log.WithField("context", "[workerZmq]")
+	log.Info("started")
+	defer func() {
+		log.Info("stopped")
+	}()
+	// sequence has all the events, rawtx is for the nodes without it
+	hasSequence := false
+	sub := zmq.NewSubscriber(c.Cfg.ZmqAddress, zmq.TopicRawTx, zmq.TopicHashBlock, zmq.TopicSequence)
+	sub.OnState = c.pushState
+	sub.Run(c.ctx, func(msg zmq.Msg) {
+		switch msg.Topic {
+		case zmq.TopicRawTx:
+			if hasSequence {
+				return
+			}
+			// block txs are sent too, the ones not in the pool are skipped
+			txid, err := zmq.RawTxid(msg.Body)
+			if err != nil {
+				log.Errorf("error on rawtx decode: %v\n", err)
+				return
+			}
+			c.pushTxAdded(txid, nil)
+		case zmq.TopicHashBlock:
+			c.pushBlock()
+		case zmq.TopicSequence:
+			hasSequence = true
+			seq, err := zmq.ParseSequence(msg.Body)
+			if err != nil {
+				log.Errorf("error on sequence parse: %v\n", err)
+				return
+			}
+			switch seq.Label {
+			case zmq.SequenceTxAdded:
+				c.pushTxAdded(seq.Hash, nil)
+			case zmq.SequenceTxRemoved:
+				c.pushTxRemoved(seq.Hash)
+			case zmq.SequenceBlockConnect, zmq.SequenceBlockDisconnect:
+				c.pushBlock()
+			}
+		}
+	})
+}
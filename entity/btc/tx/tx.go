@@ -1,5 +1,10 @@
 package tx
 
+import (
+	"fmt"
+	"math"
+)
+
 /*
 Decoding transaction is 2 step process:
 
@@ -125,13 +130,94 @@ type ScriptPubKey struct {
 	ReqSigs   int      `json:"reqSigs"`
 	Type      string   `json:"type"`
 	Addresses []string `json:"addresses"`
+	Address   string   `json:"address"` // bitcoin core 22+ has single address
+}
+
+func (s *ScriptPubKey) GetAddresses() []string {
+	if s.Address != "" {
+		return append([]string{s.Address}, s.Addresses...)
+	}
+	return s.Addresses
+}
+
+// btc to sats, rounded to avoid float errors
+func BtcToSat(v float64) uint64 {
+	return uint64(math.Round(v * 1_0000_0000))
 }
 
 // get total out amount
 func (t *Transaction) GetTotalOut() uint64 {
-	var total float64
+	var total uint64
+	for _, v := range t.Vout {
+		total += BtcToSat(v.Value)
+	}
+	return total
+}
+
+// get out amounts in sats by vout index
+func (t *Transaction) GetOutValues() []uint64 {
+	res := make([]uint64, len(t.Vout))
+	for _, v := range t.Vout {
+		if v.N < 0 || v.N >= len(res) {
+			continue
+		}
+		res[v.N] = BtcToSat(v.Value)
+	}
+	return res
+}
+
+func (t *Transaction) IsCoinbase() bool {
+	return len(t.Vin) > 0 && t.Vin[0].Coinbase != ""
+}
+
+// has witness data
+func (t *Transaction) IsSegwit() bool {
+	for _, v := range t.Vin {
+		if len(v.Txinwitness) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// creates taproot outputs or spends taproot by key path.
+// key path spend is guessed by the single schnorr signature in witness (64 or 65 bytes)
+func (t *Transaction) IsTaproot() bool {
 	for _, v := range t.Vout {
-		total += v.Value
+		if v.ScriptPubKey.Type == "witness_v1_taproot" {
+			return true
+		}
+	}
+	for _, v := range t.Vin {
+		if len(v.Txinwitness) == 1 && (len(v.Txinwitness[0]) == 128 || len(v.Txinwitness[0]) == 130) {
+			return true
+		}
+	}
+	return false
+}
+
+// get unique txids of the spent outputs, coinbase has none
+func (t *Transaction) GetInputTxids() []string {
+	res := make([]string, 0, len(t.Vin))
+	seen := make(map[string]bool)
+	for _, v := range t.Vin {
+		if v.Coinbase != "" || seen[v.Txid] {
+			continue
+		}
+		seen[v.Txid] = true
+		res = append(res, v.Txid)
+	}
+	return res
+}
+
+// get spent outputs as txid:vout
+func (t *Transaction) GetOutpoints() []string {
+	res := make([]string, 0, len(t.Vin))
+	for _, v := range t.Vin {
+		if v.Coinbase != "" {
+			continue
+		}
+		res = append(res, fmt.Sprintf("%s:%d", v.Txid, v.Vout))
 	}
-	return uint64(total * 1_0000_0000)
+	return res
 }
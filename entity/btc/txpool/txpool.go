@@ -1,5 +1,7 @@
 package txpool
 
+import "github.com/1F47E/go-feesh/entity/btc/tx"
+
 // struct for custom getrawmempool response
 type TxPool struct {
 	Txid     string `json:"txid"`
@@ -11,13 +13,29 @@ type TxPool struct {
 	FeePerKB uint64 `json:"fee_kb"`
 }
 
-// struct to parse response from rawmempool true (verbose)
+// fee rate in sat/vB
+func (t *TxPool) FeeRate() float64 {
+	vsize := t.Vsize
+	if vsize == 0 {
+		vsize = (t.Weight + 3) / 4
+	}
+	if vsize == 0 {
+		vsize = t.Size
+	}
+	if vsize == 0 {
+		return 0
+	}
+	return float64(t.Fee) / float64(vsize)
+}
+
+// struct to parse response from rawmempool true (verbose) and getmempoolentry
 // with simplified fields
 // time field is only avaiable via this method.
 // if we parse just txid and then get tx via getrawtransaction - there is no time field
 // So basically doint pool parsing with verbose mode to have ordered pool list of txs
 // Also having fee is good
 /*
+btcd:
 {
     "size": 219,
     "vsize": 219,
@@ -31,6 +49,21 @@ type TxPool struct {
       "89c4151288c2c4a48d01752a66d5d7dbe210bb5c097b3a95a1a1be04451871a1"
     ]
   }
+
+bitcoin core, fee is in the fees object, no size
+{
+    "vsize": 141,
+    "weight": 561,
+    "time": 1690133895,
+    "height": 2443385,
+    "fees": {
+      "base": 0.00000564,
+      "modified": 0.00000564,
+      "ancestor": 0.00000564,
+      "descendant": 0.00000564
+    },
+    "depends": []
+  }
 */
 type TxPoolVerbose struct {
 	Txid         string   `json:"txid"`
@@ -38,10 +71,40 @@ type TxPoolVerbose struct {
 	Size         int      `json:"size"`
 	VSize        int      `json:"vsize"`
 	Weight       int      `json:"weight"`
-	Fee          string   `json:"fee"`
+	Fee          float64  `json:"fee"` // BTC
+	Fees         *Fees    `json:"fees"`
 	Time         int64    `json:"time"`
 	Height       int      `json:"height"`
-	StartingPrio int      `json:"startingpriority"`
-	CurrentPrio  int      `json:"currentpriority"`
+	StartingPrio float64  `json:"startingpriority"`
+	CurrentPrio  float64  `json:"currentpriority"`
 	Depends      []string `json:"depends"`
 }
+
+// BTC
+type Fees struct {
+	Base     float64 `json:"base"`
+	Modified float64 `json:"modified"`
+}
+
+// same format as the patched getrawmempool
+func (t *TxPoolVerbose) ToTxPool(txid string) TxPool {
+	fee := t.Fee
+	if t.Fees != nil {
+		fee = t.Fees.Base
+	}
+	ret := TxPool{
+		Txid:   txid,
+		Time:   t.Time,
+		Size:   uint32(t.Size),
+		Vsize:  uint32(t.VSize),
+		Weight: uint32(t.Weight),
+		Fee:    tx.BtcToSat(fee),
+	}
+	if ret.Size == 0 {
+		ret.Size = ret.Vsize
+	}
+	if ret.Size > 0 {
+		ret.FeePerKB = ret.Fee * 1000 / uint64(ret.Size)
+	}
+	return ret
+}
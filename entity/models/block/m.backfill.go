@@ -0,0 +1,22 @@
+package block
+
+import "time"
+
+const (
+	BackfillBackward = "backward"
+	BackfillForward  = "forward"
+)
+
+// historical blocks backfill progress, stored to resume after restart
+type Backfill struct {
+	Direction    string    `json:"direction"`
+	StartHeight  int       `json:"start_height"`
+	TargetHeight int       `json:"target_height"`
+	NextHeight   int       `json:"next_height"` // next block to process
+	Done         int       `json:"done"`
+	Total        int       `json:"total"`
+	Finished     bool      `json:"finished"`
+	StartedAt    time.Time `json:"started_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	EtaSeconds   int64     `json:"eta_seconds"`
+}
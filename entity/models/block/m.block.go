@@ -3,13 +3,30 @@ package block
 import "github.com/btcsuite/btcd/btcutil"
 
 type Block struct {
-	Hash   string `json:"hash"`
-	Height int    `json:"height"`
-	Value  uint64 `json:"value"`
-	Fee    uint64 `json:"fee"`
-	Weight uint64 `json:"weight"`
-	Size   uint64 `json:"size"`
-	Txs    uint64 `json:"txs"`
+	Hash     string `json:"hash"`
+	Height   int    `json:"height"`
+	Time     int64  `json:"time"`
+	Interval int64  `json:"interval"` // seconds since the previous block
+	Pool     string `json:"pool"`
+	Value    uint64 `json:"value"`
+	Fee      uint64 `json:"fee"`
+	Reward   uint64 `json:"reward"`  // coinbase out, subsidy + fees
+	Subsidy  uint64 `json:"subsidy"` // new coins
+	Weight   uint64 `json:"weight"`
+	Size     uint64 `json:"size"`
+	VSize    uint64 `json:"vsize"`
+	Txs      uint64 `json:"txs"`
+	// txs parsed with all the amounts known
+	TxsParsed uint64 `json:"txs_parsed"`
+	// fee rates of the block txs, sat/vB
+	FeeRateMin    float64 `json:"fee_rate_min"`
+	FeeRateP10    float64 `json:"fee_rate_p10"`
+	FeeRateMedian float64 `json:"fee_rate_median"`
+	FeeRateP90    float64 `json:"fee_rate_p90"`
+	FeeRateMax    float64 `json:"fee_rate_max"`
+	// share of the non coinbase txs
+	SegwitShare  float64 `json:"segwit_share"`
+	TaprootShare float64 `json:"taproot_share"`
 }
 
 func (b *Block) ValueString() string {
@@ -20,6 +37,11 @@ func (b *Block) FeeString() string {
 	return btcutil.Amount(b.Fee).String()
 }
 
+func (b *Block) SubsidyString() string {
+	return btcutil.Amount(b.Subsidy).String()
+}
+
+// every tx is parsed, so value and fee are final
 func (b *Block) IsComplete() bool {
-	return b.Value != 0 && b.Fee != 0
+	return b.Txs > 0 && b.TxsParsed == b.Txs
 }
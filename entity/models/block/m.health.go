@@ -0,0 +1,18 @@
+package block
+
+import "time"
+
+// mined block compared against the projected template from the pool before it.
+// match is the share of txs in both out of all the txs in either, percents
+type Health struct {
+	Hash         string    `json:"hash"`
+	Height       int       `json:"height"`
+	Match        float64   `json:"match"`
+	Expected     int       `json:"expected"` // txs in the template
+	Mined        int       `json:"mined"`    // txs in the block, without coinbase
+	Matched      int       `json:"matched"`
+	Missing      []string  `json:"missing"`    // expected but not mined
+	Unexpected   []string  `json:"unexpected"` // mined but not expected
+	TemplateTime time.Time `json:"template_time"`
+	Time         time.Time `json:"time"`
+}
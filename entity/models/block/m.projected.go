@@ -0,0 +1,15 @@
+package block
+
+// block template projected from the current pool
+// fee rates are in sat/vB
+type Projected struct {
+	Index         int      `json:"index"`
+	Txs           int      `json:"tx_count"`
+	VSize         uint64   `json:"vsize"`
+	Weight        uint64   `json:"weight"`
+	Fee           uint64   `json:"fee"`
+	FeeRateMin    float64  `json:"fee_rate_min"`
+	FeeRateMedian float64  `json:"fee_rate_median"`
+	FeeRateMax    float64  `json:"fee_rate_max"`
+	Txids         []string `json:"-"`
+}
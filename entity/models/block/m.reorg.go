@@ -0,0 +1,13 @@
+package block
+
+import "time"
+
+// parsed blocks orphaned by the new best chain
+type Reorg struct {
+	ForkHeight int       `json:"fork_height"`
+	ForkHash   string    `json:"fork_hash"`
+	Depth      int       `json:"depth"`
+	Stale      []string  `json:"stale"`
+	Tip        string    `json:"tip"`
+	Time       time.Time `json:"time"`
+}
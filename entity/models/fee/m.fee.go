@@ -0,0 +1,10 @@
+package fee
+
+// recommended fee rates in sat/vB for the common confirmation targets
+type Recommended struct {
+	NextBlock uint `json:"next_block"`
+	HalfHour  uint `json:"half_hour"`
+	Hour      uint `json:"hour"`
+	Economy   uint `json:"economy"`
+	Minimum   uint `json:"minimum"`
+}
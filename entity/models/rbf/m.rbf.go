@@ -0,0 +1,13 @@
+package rbf
+
+import "time"
+
+// tx replaced by another one spending the same outputs
+type Replacement struct {
+	Txid       string    `json:"txid"`
+	ReplacedBy string    `json:"replaced_by"`
+	Fee        uint64    `json:"fee"`
+	FeeNew     uint64    `json:"fee_new"`
+	FeeDelta   int64     `json:"fee_delta"`
+	Time       time.Time `json:"time"`
+}
@@ -0,0 +1,24 @@
+package removal
+
+import "time"
+
+// why the tx left the pool
+type Reason string
+
+const (
+	ReasonConfirmed Reason = "confirmed"
+	ReasonReplaced  Reason = "replaced"
+	ReasonEvicted   Reason = "evicted"
+)
+
+type Removal struct {
+	Txid        string    `json:"txid"`
+	Reason      Reason    `json:"reason"`
+	BlockHash   string    `json:"block_hash,omitempty"`
+	BlockHeight int       `json:"block_height,omitempty"`
+	ReplacedBy  string    `json:"replaced_by,omitempty"`
+	Fee         uint64    `json:"fee"`
+	FeeRate     float64   `json:"fee_rate"`
+	FirstSeen   time.Time `json:"first_seen"`
+	Time        time.Time `json:"time"`
+}
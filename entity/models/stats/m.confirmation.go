@@ -0,0 +1,17 @@
+package stats
+
+type Percentiles struct {
+	P10 float64 `json:"p10"`
+	P25 float64 `json:"p25"`
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+	P90 float64 `json:"p90"`
+}
+
+// how long the txs of the fee rate band waited for the confirmation
+type ConfirmationTimes struct {
+	FeeRate uint        `json:"fee_rate"` // upper bound of the band in sat/vB, last one is 500+
+	Samples int         `json:"samples"`
+	Blocks  Percentiles `json:"blocks"`
+	Seconds Percentiles `json:"seconds"`
+}
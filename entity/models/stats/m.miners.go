@@ -0,0 +1,18 @@
+package stats
+
+// mining pool share over the window of the last blocks
+type Miner struct {
+	Pool   string  `json:"pool"`
+	Blocks int     `json:"blocks"`
+	Share  float64 `json:"share"`
+	Fee    uint64  `json:"fee"`    // fee revenue, sats
+	Reward uint64  `json:"reward"` // subsidy + fees, sats
+}
+
+type Miners struct {
+	Window     int     `json:"window"`
+	FromHeight int     `json:"from_height"`
+	ToHeight   int     `json:"to_height"`
+	Blocks     int     `json:"blocks"` // blocks with stats in the window
+	Pools      []Miner `json:"pools"`
+}
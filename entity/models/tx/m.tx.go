@@ -17,6 +17,22 @@ type Tx struct {
 	AmountOut uint64 `json:"amount_out"`
 	AmountIn  uint64 `json:"amount_in"`
 	Fits      bool   `json:"fits"`
+	Coinbase  bool   `json:"coinbase,omitempty"`
+	Pool      string `json:"pool,omitempty"` // mining pool, coinbase only
+	Segwit    bool   `json:"segwit,omitempty"`
+	Taproot   bool   `json:"taproot,omitempty"`
+	// txids of the spent outputs. only the ones still in the pool are real parents
+	Depends []string `json:"depends,omitempty"`
+	// spent outputs as txid:vout, used to detect replacements
+	Spends []string `json:"spends,omitempty"`
+	// package stats, counts and sizes include the tx itself. sizes in vbytes
+	AncestorCount    int     `json:"ancestor_count"`
+	AncestorSize     uint64  `json:"ancestor_size"`
+	AncestorFees     uint64  `json:"ancestor_fees"`
+	DescendantCount  int     `json:"descendant_count"`
+	DescendantSize   uint64  `json:"descendant_size"`
+	DescendantFees   uint64  `json:"descendant_fees"`
+	EffectiveFeeRate float64 `json:"effective_fee_rate"`
 }
 
 func (t *Tx) FeePerKb() uint {
@@ -27,6 +43,37 @@ func (t *Tx) FeePerByte() uint {
 	return uint(float64(t.Fee) / float64(t.Size))
 }
 
+// virtual size, weight / 4 rounded up
+// falls back to raw size if node did not report the weight
+func (t *Tx) VSize() uint32 {
+	if t.Weight == 0 {
+		return t.Size
+	}
+	return (t.Weight + 3) / 4
+}
+
+// fee rate in sat/vB
+func (t *Tx) FeePerVByte() float64 {
+	vsize := t.VSize()
+	if vsize == 0 {
+		return 0
+	}
+	return float64(t.Fee) / float64(vsize)
+}
+
+// effective (CPFP aware) fee rate if calculated, own fee rate otherwise
+func (t *Tx) FeeRate() float64 {
+	if t.EffectiveFeeRate > 0 {
+		return t.EffectiveFeeRate
+	}
+	return t.FeePerVByte()
+}
+
+// all the amounts are known. coinbase has no inputs
+func (t *Tx) IsComplete() bool {
+	return t.Coinbase || t.AmountIn > 0
+}
+
 func (t *Tx) FeeString() string {
 	return btcutil.Amount(t.Fee).String()
 }
@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/1F47E/go-feesh/api"
 	"github.com/1F47E/go-feesh/client"
@@ -25,7 +26,9 @@ import (
 	mblock "github.com/1F47E/go-feesh/entity/models/block"
 	"github.com/1F47E/go-feesh/logger"
 	"github.com/1F47E/go-feesh/notificator"
+	"github.com/1F47E/go-feesh/storage"
 	smap "github.com/1F47E/go-feesh/storage/map"
+	sredis "github.com/1F47E/go-feesh/storage/redis"
 
 	// docs are generated by Swag CLI
 	_ "github.com/1F47E/go-feesh/docs"
@@ -64,6 +67,11 @@ func main() {
 	var err error
 	cfg := config.NewConfig()
 
+	// TODO: graceful shutdown
+	// cancelling aborts the RPC calls in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	if os.Getenv("DRY") != "1" {
 
 		// create RPC client
@@ -71,22 +79,52 @@ func main() {
 		if err != nil {
 			log.Fatalln("error creating client:", err)
 		}
+		cli.SetTimeouts(client.Timeouts{
+			Default: time.Duration(cfg.RpcTimeout) * time.Second,
+			Info:    time.Duration(cfg.RpcTimeoutInfo) * time.Second,
+			Mempool: time.Duration(cfg.RpcTimeoutMempool) * time.Second,
+		})
+		cli.SetBatchSize(cfg.RpcBatchSize)
+		// debug sessions
+		if cfg.ReplayFile != "" {
+			r, err := client.NewReplayer(cfg.ReplayFile, float64(cfg.ReplaySpeed))
+			if err != nil {
+				log.Fatalln("error on loading replay:", err)
+			}
+			cli.SetReplayer(r)
+		}
+		if cfg.RecordFile != "" {
+			rec, err := client.NewRecorder(cfg.RecordFile)
+			if err != nil {
+				log.Fatalln("error on creating recording:", err)
+			}
+			// records are flushed one by one, file is readable without closing
+			defer rec.Close()
+			cli.SetRecorder(rec)
+		}
+		for _, host := range cfg.RpcBackends {
+			cli.AddBackend(host, cfg.RpcUser, cfg.RpcPass)
+		}
+		// stock bitcoin core has no btcd specific methods
+		if err := cli.Detect(ctx); err != nil {
+			log.Fatalln("error on detecting node capabilities:", err)
+		}
 
 		// get node info
-		info, err := cli.GetInfo()
+		info, err := cli.GetInfo(ctx)
 		if err != nil {
 			log.Fatalln("error on getinfo:", err)
 		}
 		log.Printf("node info: %+v\n", info)
 
 		// get last block hash
-		bestBlock, err := cli.GetBestBlock()
+		bestBlock, err := cli.GetBestBlock(ctx)
 		if err != nil {
 			log.Fatalln("error on getbestblock:", err)
 		}
 		log.Println("last block hash:", bestBlock.Hash)
 
-		b, err := cli.GetBlock(bestBlock.Hash)
+		b, err := cli.GetBlock(ctx, bestBlock.Hash)
 		if err != nil {
 			log.Fatalln("error on getblock:", err)
 		}
@@ -128,28 +166,37 @@ func main() {
 	// }
 	// log.Println("block tx cnt:", len(b.Transactions))
 
-	// TODO: graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	// create storage
-
-	// create in mem storage (debug only)
-	strg := smap.New()
-	// create redis storage
-	// s, err := sredis.New(ctx)
-	// if err != nil {
-	// 	log.Fatalln("error on redis storage:", err)
-	// }
+	var strg storage.PoolRepository
+	switch cfg.Storage {
+	case "redis":
+		strg, err = sredis.New(ctx, sredis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+			Prefix:   cfg.RedisPrefix,
+			TxTTL:    time.Duration(cfg.RedisTxTTL) * time.Hour,
+		})
+		if err != nil {
+			log.Fatalln("error on redis storage:", err)
+		}
+	case "map":
+		// in mem storage, lost on restart
+		strg = smap.New()
+	default:
+		log.Fatalln("unknown STORAGE:", cfg.Storage)
+	}
 
 	// common channel for WS notifications
 	broadcastCh := make(chan notificator.Msg)
+	// WS events (rbf etc)
+	eventsCh := make(chan notificator.Event)
 
 	// WS notificator
-	noficator := notificator.New(broadcastCh)
+	noficator := notificator.New(broadcastCh, eventsCh)
 
 	// create core with RPC client and storage
-	c := core.NewCore(ctx, cfg, cli, strg, broadcastCh)
+	c := core.NewCore(ctx, cfg, cli, strg, broadcastCh, eventsCh)
 
 	// create API with WS
 	a := api.NewApi(c, noficator)
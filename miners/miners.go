@@ -0,0 +1,89 @@
+package miners
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+)
+
+const Unknown = "unknown"
+
+// bundled pools table, can be replaced with POOLS_FILE without rebuild
+//
+//go:embed pools.json
+var poolsJson []byte
+
+type Pool struct {
+	Name      string   `json:"name"`
+	Tags      []string `json:"tags"`
+	Addresses []string `json:"addresses"`
+}
+
+// Miners matches blocks to pools by coinbase payout address or tag
+type Miners struct {
+	pools     []Pool
+	addresses map[string]string // address -> pool name
+}
+
+// load pools table from file, bundled one if path is empty
+func New(path string) (*Miners, error) {
+	data := poolsJson
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var pools []Pool
+	if err := json.Unmarshal(data, &pools); err != nil {
+		return nil, err
+	}
+	m := &Miners{
+		pools:     pools,
+		addresses: make(map[string]string),
+	}
+	for _, p := range pools {
+		for _, addr := range p.Addresses {
+			m.addresses[addr] = p.Name
+		}
+	}
+	return m, nil
+}
+
+// pool name of the coinbase tx.
+// payout address is more reliable than the tag, so its checked first
+func (m *Miners) Identify(coinbase *tx.Transaction) string {
+	for _, out := range coinbase.Vout {
+		for _, addr := range out.ScriptPubKey.GetAddresses() {
+			if name, ok := m.addresses[addr]; ok {
+				return name
+			}
+		}
+	}
+	if len(coinbase.Vin) == 0 {
+		return Unknown
+	}
+	tag := strings.ToLower(decodeTag(coinbase.Vin[0].Coinbase))
+	for _, p := range m.pools {
+		for _, t := range p.Tags {
+			if strings.Contains(tag, strings.ToLower(t)) {
+				return p.Name
+			}
+		}
+	}
+	return Unknown
+}
+
+// coinbase script as text, binary data is dropped
+func decodeTag(script string) string {
+	data, err := hex.DecodeString(script)
+	if err != nil {
+		return ""
+	}
+	return strings.ToValidUTF8(string(data), "")
+}
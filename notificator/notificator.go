@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"sync"
 
+	mfee "github.com/1F47E/go-feesh/entity/models/fee"
 	"github.com/1F47E/go-feesh/logger"
 	"github.com/gofiber/websocket/v2"
 )
@@ -11,14 +12,26 @@ import (
 var log = logger.Log.WithField("scope", "notificator")
 
 type Msg struct {
-	Height          int      `json:"height"`
-	PoolSize        int      `json:"size"`
-	PoolSizeHistory [20]uint `json:"size_history"`
-	TotalFee        int      `json:"fee"`
-	AvgFee          int      `json:"avg_fee"`
-	Amount          int      `json:"amount"`
-	Size            int      `json:"weight"`
-	FeeBuckets      [24]uint `json:"fee_buckets"`
+	Height          int              `json:"height"`
+	PoolSize        int              `json:"size"`
+	PoolSizeHistory [20]uint         `json:"size_history"`
+	TotalFee        int              `json:"fee"`
+	AvgFee          int              `json:"avg_fee"`
+	Amount          int              `json:"amount"`
+	Size            int              `json:"weight"`
+	FeeBuckets      [24]uint         `json:"fee_buckets"`
+	Fees            mfee.Recommended `json:"fees"`
+}
+
+// event types pushed along with pool updates
+const (
+	EventRbf   = "rbf"
+	EventReorg = "reorg"
+)
+
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
 }
 
 type client struct {
@@ -31,15 +44,17 @@ type Notificator struct {
 	UnregisterCh       chan *websocket.Conn
 	clients            map[*websocket.Conn]*client
 	broadcastCh        chan Msg
+	eventsCh           chan Event
 	lastBroadcastedMsg Msg
 }
 
-func New(notificationsCh chan Msg) *Notificator {
+func New(notificationsCh chan Msg, eventsCh chan Event) *Notificator {
 	return &Notificator{
 		RegisterCh:   make(chan *websocket.Conn),
 		UnregisterCh: make(chan *websocket.Conn),
 		clients:      make(map[*websocket.Conn]*client),
 		broadcastCh:  notificationsCh,
+		eventsCh:     eventsCh,
 	}
 }
 
@@ -66,33 +81,11 @@ func (n *Notificator) workerWsHub() {
 			}
 			n.lastBroadcastedMsg = msg
 			log.Debugf("message received: %+v", msg)
-			// Send the message to all clients
-			for connection, c := range n.clients {
-				go func(connection *websocket.Conn, c *client) {
-					c.mu.Lock()
-					defer c.mu.Unlock()
-					if c.isClosing {
-						return
-					}
-					// serialize message
-					msgBytes, err := json.Marshal(msg)
-					if err != nil {
-						log.Errorf("error on marshal msg: %v", err)
-						return
-					}
-					if err := connection.WriteMessage(websocket.TextMessage, msgBytes); err != nil {
-						c.isClosing = true
-						log.Println("write error:", err)
-
-						err = connection.WriteMessage(websocket.CloseMessage, []byte{})
-						if err != nil {
-							log.Errorf("close error: %v", err)
-						}
-						connection.Close()
-						n.UnregisterCh <- connection
-					}
-				}(connection, c)
-			}
+			n.broadcast(msg)
+
+		case ev := <-n.eventsCh:
+			log.Debugf("event received: %s", ev.Type)
+			n.broadcast(ev)
 
 		case connection := <-n.UnregisterCh:
 			// Remove the client from the hub
@@ -103,6 +96,36 @@ func (n *Notificator) workerWsHub() {
 	}
 }
 
+// send the message to all clients
+func (n *Notificator) broadcast(msg interface{}) {
+	// serialize message
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf("error on marshal msg: %v", err)
+		return
+	}
+	for connection, c := range n.clients {
+		go func(connection *websocket.Conn, c *client) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.isClosing {
+				return
+			}
+			if err := connection.WriteMessage(websocket.TextMessage, msgBytes); err != nil {
+				c.isClosing = true
+				log.Println("write error:", err)
+
+				err = connection.WriteMessage(websocket.CloseMessage, []byte{})
+				if err != nil {
+					log.Errorf("close error: %v", err)
+				}
+				connection.Close()
+				n.UnregisterCh <- connection
+			}
+		}(connection, c)
+	}
+}
+
 // demo ws msg
 // func (n *Notificator) workerWsDemo() {
 // 	cnt := 0
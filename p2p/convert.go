@@ -0,0 +1,82 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/1F47E/go-feesh/entity/btc/tx"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// network params by name, same as the btcd flags
+func Params(network string) (*chaincfg.Params, error) {
+	switch network {
+	case "", "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet", "testnet3":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	case "signet":
+		return &chaincfg.SigNetParams, nil
+	case "simnet":
+		return &chaincfg.SimNetParams, nil
+	}
+	return nil, fmt.Errorf("unknown network %s", network)
+}
+
+// wire tx to the same model as getrawtransaction verbose, so no RPC is needed
+func ToTransaction(msg *wire.MsgTx, params *chaincfg.Params) *tx.Transaction {
+	size := msg.SerializeSize()
+	t := &tx.Transaction{
+		Txid:     msg.TxHash().String(),
+		Version:  int(msg.Version),
+		Locktime: int(msg.LockTime),
+		Size:     size,
+		Weight:   msg.SerializeSizeStripped()*3 + size,
+		Vin:      make([]tx.Vin, 0, len(msg.TxIn)),
+		Vout:     make([]tx.Vout, 0, len(msg.TxOut)),
+	}
+	coinbase := isCoinbase(msg)
+	for _, in := range msg.TxIn {
+		vin := tx.Vin{Sequence: uint64(in.Sequence)}
+		if coinbase {
+			vin.Coinbase = hex.EncodeToString(in.SignatureScript)
+		} else {
+			vin.Txid = in.PreviousOutPoint.Hash.String()
+			vin.Vout = int(in.PreviousOutPoint.Index)
+			vin.ScriptSig = tx.ScriptSig{Hex: hex.EncodeToString(in.SignatureScript)}
+		}
+		for _, w := range in.Witness {
+			vin.Txinwitness = append(vin.Txinwitness, hex.EncodeToString(w))
+		}
+		t.Vin = append(t.Vin, vin)
+	}
+	for i, out := range msg.TxOut {
+		class, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(out.PkScript, params)
+		spk := tx.ScriptPubKey{
+			Hex:     hex.EncodeToString(out.PkScript),
+			Type:    class.String(),
+			ReqSigs: reqSigs,
+		}
+		for _, a := range addrs {
+			spk.Addresses = append(spk.Addresses, a.EncodeAddress())
+		}
+		t.Vout = append(t.Vout, tx.Vout{
+			Value:        float64(out.Value) / 1_0000_0000,
+			N:            i,
+			ScriptPubKey: spk,
+		})
+	}
+	return t
+}
+
+func isCoinbase(msg *wire.MsgTx) bool {
+	if len(msg.TxIn) != 1 {
+		return false
+	}
+	prev := msg.TxIn[0].PreviousOutPoint
+	return prev.Index == wire.MaxPrevOutIndex && prev.Hash == chainhashZero
+}
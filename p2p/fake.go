@@ -0,0 +1,133 @@
+package p2p
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+
+	"github.com/1F47E/go-feesh/logger"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// FakePeer is a node stand-in for the local testing.
+// it accepts the connections, announces txs and blocks and serves the txs on getdata
+type FakePeer struct {
+	ln     net.Listener
+	params *chaincfg.Params
+	mu     *sync.Mutex
+	conns  map[*peer]bool
+	txs    map[chainhash.Hash]*wire.MsgTx
+}
+
+func NewFakePeer(addr string, params *chaincfg.Params) (*FakePeer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	f := &FakePeer{
+		ln:     ln,
+		params: params,
+		mu:     &sync.Mutex{},
+		conns:  make(map[*peer]bool),
+		txs:    make(map[chainhash.Hash]*wire.MsgTx),
+	}
+	go f.accept()
+	return f, nil
+}
+
+func (f *FakePeer) Addr() string {
+	return f.ln.Addr().String()
+}
+
+func (f *FakePeer) Close() error {
+	f.mu.Lock()
+	for p := range f.conns {
+		p.close()
+	}
+	f.mu.Unlock()
+	return f.ln.Close()
+}
+
+func (f *FakePeer) accept() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.serve(&peer{addr: conn.RemoteAddr().String(), params: f.params, conn: conn})
+	}
+}
+
+func (f *FakePeer) serve(p *peer) {
+	log := logger.Log.WithField("context", "[P2P fake]")
+	defer p.close()
+	if err := p.handshake(); err != nil {
+		log.Errorf("handshake: %v\n", err)
+		return
+	}
+	f.mu.Lock()
+	f.conns[p] = true
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, p)
+		f.mu.Unlock()
+	}()
+	for {
+		msg, err := p.read(idleTimeout)
+		if err != nil {
+			return
+		}
+		switch m := msg.(type) {
+		case *wire.MsgPing:
+			f.send(p, wire.NewMsgPong(m.Nonce))
+		case *wire.MsgGetData:
+			for _, iv := range m.InvList {
+				f.mu.Lock()
+				t, ok := f.txs[iv.Hash]
+				f.mu.Unlock()
+				if ok {
+					f.send(p, t)
+				}
+			}
+		}
+	}
+}
+
+// writes to the peer are serialized, announcements come from the other goroutines
+func (f *FakePeer) send(p *peer, msg wire.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = p.write(msg)
+}
+
+// announce the tx to all the connected peers
+func (f *FakePeer) AnnounceTx(t *wire.MsgTx) {
+	hash := t.TxHash()
+	f.mu.Lock()
+	f.txs[hash] = t
+	f.mu.Unlock()
+	inv := wire.NewMsgInv()
+	_ = inv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &hash))
+	f.broadcast(inv)
+}
+
+func (f *FakePeer) AnnounceBlock(hash chainhash.Hash) {
+	inv := wire.NewMsgInv()
+	_ = inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &hash))
+	f.broadcast(inv)
+}
+
+func (f *FakePeer) Ping() {
+	f.broadcast(wire.NewMsgPing(rand.Uint64()))
+}
+
+func (f *FakePeer) broadcast(msg wire.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for p := range f.conns {
+		_ = p.write(msg)
+	}
+}
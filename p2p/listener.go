@@ -0,0 +1,227 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/1F47E/go-feesh/logger"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// pause before reconnect
+var reconnectDelay = 10 * time.Second
+
+// how long to remember the seen txs
+var seenRetention = 2 * time.Hour
+
+// requested tx is asked again from the next peer that announces it after this.
+// same as bitcoin core does for the peers that never deliver
+var requestTimeout = 1 * time.Minute
+
+// when and where the tx was announced first
+type Seen struct {
+	Time  time.Time `json:"time"`
+	Order uint64    `json:"order"` // arrival order across all the peers
+	Peer  string    `json:"peer"`
+
+	requested time.Time // last getdata
+	received  bool
+}
+
+// Listener connects to the peers and listens for the announced txs and blocks.
+// txs are requested from the first peer that announced them,
+// and from the next one if the tx is not delivered in requestTimeout
+type Listener struct {
+	params *chaincfg.Params
+	addrs  []string
+
+	mu    *sync.Mutex
+	seen  map[string]Seen
+	order uint64
+
+	OnTx    func(t *wire.MsgTx, seen Seen)
+	OnBlock func(hash string)
+	// peer connection state changes
+	OnState func(connected bool)
+}
+
+func NewListener(params *chaincfg.Params, addrs []string) *Listener {
+	return &Listener{
+		params: params,
+		addrs:  addrs,
+		mu:     &sync.Mutex{},
+		seen:   make(map[string]Seen),
+	}
+}
+
+// blocks until the context is done
+func (l *Listener) Run(ctx context.Context) {
+	wg := &sync.WaitGroup{}
+	for _, addr := range l.addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			l.runPeer(ctx, addr)
+		}(addr)
+	}
+	go l.cleanup(ctx)
+	wg.Wait()
+}
+
+func (l *Listener) FirstSeen(txid string) (Seen, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.seen[txid]
+	return s, ok
+}
+
+func (l *Listener) runPeer(ctx context.Context, addr string) {
+	log := logger.Log.WithField("context", "[P2P "+addr+"]")
+	for {
+		err := l.listen(ctx, addr)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("peer error: %v, reconnecting in %s\n", err, reconnectDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context, addr string) error {
+	p, err := dial(ctx, addr, l.params)
+	if err != nil {
+		return err
+	}
+	defer p.close()
+	// unblock the read on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.close()
+		case <-done:
+		}
+	}()
+	logger.Log.WithField("context", "[P2P "+addr+"]").Info("connected")
+	if l.OnState != nil {
+		l.OnState(true)
+		defer l.OnState(false)
+	}
+
+	for {
+		msg, err := p.read(idleTimeout)
+		if err != nil {
+			return err
+		}
+		switch m := msg.(type) {
+		case *wire.MsgPing:
+			if err := p.write(wire.NewMsgPong(m.Nonce)); err != nil {
+				return err
+			}
+		case *wire.MsgInv:
+			if err := l.handleInv(p, m); err != nil {
+				return err
+			}
+		case *wire.MsgTx:
+			seen, ok := l.receive(m.TxHash().String())
+			if !ok {
+				continue
+			}
+			if l.OnTx != nil {
+				l.OnTx(m, seen)
+			}
+		case *wire.MsgBlock:
+			if l.OnBlock != nil {
+				l.OnBlock(m.BlockHash().String())
+			}
+		case *wire.MsgHeaders:
+			for _, h := range m.Headers {
+				if l.OnBlock != nil {
+					l.OnBlock(h.BlockHash().String())
+				}
+			}
+		}
+	}
+}
+
+// ask for the txs not seen before, report the blocks
+func (l *Listener) handleInv(p *peer, inv *wire.MsgInv) error {
+	getdata := wire.NewMsgGetData()
+	now := time.Now()
+	for _, iv := range inv.InvList {
+		switch iv.Type {
+		case wire.InvTypeTx, wire.InvTypeWitnessTx:
+			txid := iv.Hash.String()
+			l.mu.Lock()
+			s, ok := l.seen[txid]
+			if !ok {
+				l.order++
+				s = Seen{Time: now, Order: l.order, Peer: p.addr}
+			}
+			// asked already, but the peer could never deliver
+			request := !s.received && now.Sub(s.requested) > requestTimeout
+			if request {
+				s.requested = now
+			}
+			l.seen[txid] = s
+			l.mu.Unlock()
+			if !request {
+				continue
+			}
+			// witness is needed for the weight
+			_ = getdata.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessTx, &iv.Hash))
+		case wire.InvTypeBlock, wire.InvTypeWitnessBlock:
+			if l.OnBlock != nil {
+				l.OnBlock(iv.Hash.String())
+			}
+		}
+	}
+	if len(getdata.InvList) == 0 {
+		return nil
+	}
+	return p.write(getdata)
+}
+
+// mark the tx delivered, false if it was delivered already by another peer
+func (l *Listener) receive(txid string) (Seen, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.seen[txid]
+	if !ok {
+		// not announced, sent by the peer on its own
+		return s, true
+	}
+	if s.received {
+		return s, false
+	}
+	s.received = true
+	l.seen[txid] = s
+	return s, true
+}
+
+func (l *Listener) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(seenRetention / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deadline := time.Now().Add(-seenRetention)
+			l.mu.Lock()
+			for txid, s := range l.seen {
+				if s.Time.Before(deadline) {
+					delete(l.seen, txid)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
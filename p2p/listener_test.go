@@ -0,0 +1,238 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/logger"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DEBUG") != "1" {
+		logger.Log.SetLevel(logrus.ErrorLevel)
+	}
+	reconnectDelay = 10 * time.Millisecond
+	os.Exit(m.Run())
+}
+
+var testParams = &chaincfg.RegressionNetParams
+
+type testTx struct {
+	tx   *wire.MsgTx
+	seen Seen
+}
+
+// listener over the peers, callbacks are sent to the channels
+func runTestListener(t *testing.T, addrs ...string) (*Listener, chan testTx, chan string, chan bool) {
+	t.Helper()
+	txs := make(chan testTx, 100)
+	blocks := make(chan string, 100)
+	states := make(chan bool, 100)
+	l := NewListener(testParams, addrs)
+	l.OnTx = func(tx *wire.MsgTx, seen Seen) { txs <- testTx{tx, seen} }
+	l.OnBlock = func(hash string) { blocks <- hash }
+	l.OnState = func(connected bool) { states <- connected }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	for range addrs {
+		select {
+		case ok := <-states:
+			if !ok {
+				t.Fatal("disconnected")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no handshake")
+		}
+	}
+	return l, txs, blocks, states
+}
+
+func newTestPeer(t *testing.T) *FakePeer {
+	t.Helper()
+	f, err := NewFakePeer("127.0.0.1:0", testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// fake registers the connection after its side of the handshake
+func waitConns(t *testing.T, f *FakePeer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		got := len(f.conns)
+		f.mu.Unlock()
+		if got == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("fake peer has no %d connections", n)
+}
+
+func newMsgTx(n byte) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{n}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	return tx
+}
+
+func nextTx(t *testing.T, txs chan testTx) testTx {
+	t.Helper()
+	select {
+	case tx := <-txs:
+		return tx
+	case <-time.After(5 * time.Second):
+		t.Fatal("no tx")
+	}
+	return testTx{}
+}
+
+func TestListener(t *testing.T) {
+	f := newTestPeer(t)
+	l, txs, blocks, states := runTestListener(t, f.Addr())
+	waitConns(t, f, 1)
+
+	// inv -> getdata -> tx
+	a, b := newMsgTx(1), newMsgTx(2)
+	f.AnnounceTx(a)
+	f.AnnounceTx(b)
+	got := nextTx(t, txs)
+	if got.tx.TxHash() != a.TxHash() || got.seen.Order != 1 || got.seen.Peer != f.Addr() {
+		t.Fatalf("first tx %s, seen %+v", got.tx.TxHash(), got.seen)
+	}
+	got = nextTx(t, txs)
+	if got.tx.TxHash() != b.TxHash() || got.seen.Order != 2 {
+		t.Fatalf("second tx %s, seen %+v", got.tx.TxHash(), got.seen)
+	}
+	if seen, ok := l.FirstSeen(a.TxHash().String()); !ok || seen.Order != 1 {
+		t.Fatalf("first seen %+v, %v", seen, ok)
+	}
+
+	// delivered already, not asked again
+	f.AnnounceTx(a)
+	f.Ping()
+	hash := chainhash.Hash{0xbb}
+	f.AnnounceBlock(hash)
+	select {
+	case h := <-blocks:
+		if h != hash.String() {
+			t.Fatalf("block %s, want %s", h, hash)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no block")
+	}
+	// would come after the second a, peer answers in order
+	c := newMsgTx(3)
+	f.AnnounceTx(c)
+	if got := nextTx(t, txs); got.tx.TxHash() != c.TxHash() {
+		t.Fatalf("tx %s, want %s. delivered twice?", got.tx.TxHash(), c.TxHash())
+	}
+
+	f.Close()
+	select {
+	case ok := <-states:
+		if ok {
+			t.Fatal("connected after the peer is closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no disconnect")
+	}
+}
+
+// peer that announces the tx and never delivers it
+type silentPeer struct {
+	ln      net.Listener
+	getdata chan *wire.MsgGetData
+	conn    chan *peer
+}
+
+func newSilentPeer(t *testing.T) *silentPeer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &silentPeer{ln: ln, getdata: make(chan *wire.MsgGetData, 10), conn: make(chan *peer, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		p := &peer{addr: conn.RemoteAddr().String(), params: testParams, conn: conn}
+		defer p.close()
+		if err := p.handshake(); err != nil {
+			return
+		}
+		s.conn <- p
+		for {
+			msg, err := p.read(idleTimeout)
+			if err != nil {
+				return
+			}
+			if m, ok := msg.(*wire.MsgGetData); ok {
+				s.getdata <- m
+			}
+		}
+	}()
+	return s
+}
+
+func TestListenerRequestAgain(t *testing.T) {
+	prev := requestTimeout
+	requestTimeout = 50 * time.Millisecond
+	defer func() { requestTimeout = prev }()
+
+	silent := newSilentPeer(t)
+	f := newTestPeer(t)
+	_, txs, _, _ := runTestListener(t, silent.ln.Addr().String(), f.Addr())
+	waitConns(t, f, 1)
+	sp := <-silent.conn
+
+	tx := newMsgTx(4)
+	hash := tx.TxHash()
+	inv := wire.NewMsgInv()
+	_ = inv.AddInvVect(wire.NewInvVect(wire.InvTypeTx, &hash))
+	if err := sp.write(inv); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-silent.getdata:
+		if len(m.InvList) != 1 || m.InvList[0].Hash != hash || m.InvList[0].Type != wire.InvTypeWitnessTx {
+			t.Fatalf("getdata %+v", m.InvList)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tx is not requested")
+	}
+
+	// first peer never delivers, the next announcement is requested
+	time.Sleep(2 * requestTimeout)
+	f.AnnounceTx(tx)
+	got := nextTx(t, txs)
+	if got.tx.TxHash() != hash {
+		t.Fatalf("tx %s, want %s", got.tx.TxHash(), hash)
+	}
+	// first announcement is kept
+	if got.seen.Peer != silent.ln.Addr().String() || got.seen.Order != 1 {
+		t.Fatalf("seen %+v, want the silent peer", got.seen)
+	}
+}
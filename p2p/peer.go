@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var chainhashZero chainhash.Hash
+
+// how long to wait for the handshake and for any message after it.
+// nodes ping every 2 minutes
+var (
+	handshakeTimeout = 30 * time.Second
+	idleTimeout      = 5 * time.Minute
+)
+
+const userAgent = "feesh"
+
+// single outbound connection, only listens and asks for the announced txs
+type peer struct {
+	addr   string
+	params *chaincfg.Params
+	conn   net.Conn
+}
+
+func dial(ctx context.Context, addr string, params *chaincfg.Params) (*peer, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &peer{addr: addr, params: params, conn: conn}
+	if err := p.handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	return p, nil
+}
+
+func (p *peer) write(msg wire.Message) error {
+	return wire.WriteMessage(p.conn, msg, wire.ProtocolVersion, p.params.Net)
+}
+
+// unknown messages are skipped
+func (p *peer) read(timeout time.Duration) (wire.Message, error) {
+	for {
+		if err := p.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+		msg, _, err := wire.ReadMessage(p.conn, wire.ProtocolVersion, p.params.Net)
+		if err != nil {
+			if _, ok := err.(*wire.MessageError); ok {
+				continue
+			}
+			return nil, err
+		}
+		return msg, nil
+	}
+}
+
+func (p *peer) handshake() error {
+	me := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+	you := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+	if tcp, ok := p.conn.RemoteAddr().(*net.TCPAddr); ok {
+		you = wire.NewNetAddressIPPort(tcp.IP, uint16(tcp.Port), 0)
+	}
+	ver := wire.NewMsgVersion(me, you, rand.Uint64(), 0)
+	if err := ver.AddUserAgent(userAgent, "0.1"); err != nil {
+		return err
+	}
+	if err := p.write(ver); err != nil {
+		return err
+	}
+	gotVersion, gotVerAck := false, false
+	for !gotVersion || !gotVerAck {
+		msg, err := p.read(handshakeTimeout)
+		if err != nil {
+			return err
+		}
+		switch m := msg.(type) {
+		case *wire.MsgVersion:
+			if m.ProtocolVersion < int32(wire.SendHeadersVersion) {
+				return fmt.Errorf("protocol version %d is too old", m.ProtocolVersion)
+			}
+			gotVersion = true
+			if err := p.write(wire.NewMsgVerAck()); err != nil {
+				return err
+			}
+		case *wire.MsgVerAck:
+			gotVerAck = true
+		}
+	}
+	return nil
+}
+
+func (p *peer) close() error {
+	return p.conn.Close()
+}
@@ -1,22 +1,29 @@
 package storage_map
 
 import (
+	"sort"
 	"sync"
 
+	"github.com/1F47E/go-feesh/entity/models/block"
 	"github.com/1F47E/go-feesh/entity/models/tx"
 )
 
 type MapStorage struct {
-	mu     *sync.Mutex
-	txs    map[string]*tx.Tx
-	blocks map[string][]string
+	mu          *sync.Mutex
+	txs         map[string]*tx.Tx
+	blocks      map[string][]string
+	blocksStats map[string]block.Block
+	health      map[string]block.Health
+	backfill    *block.Backfill
 }
 
 func New() *MapStorage {
 	return &MapStorage{
-		mu:     &sync.Mutex{},
-		txs:    make(map[string]*tx.Tx),
-		blocks: make(map[string][]string),
+		mu:          &sync.Mutex{},
+		txs:         make(map[string]*tx.Tx),
+		blocks:      make(map[string][]string),
+		blocksStats: make(map[string]block.Block),
+		health:      make(map[string]block.Health),
 	}
 }
 
@@ -33,6 +40,26 @@ func (m *MapStorage) TxAdd(tx tx.Tx) error {
 	return nil
 }
 
+func (m *MapStorage) TxGetMany(txids []string) ([]*tx.Tx, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]*tx.Tx, len(txids))
+	for i, txid := range txids {
+		res[i] = m.txs[txid]
+	}
+	return res, nil
+}
+
+func (m *MapStorage) TxAddMany(txs []tx.Tx) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range txs {
+		t := txs[i]
+		m.txs[t.Hash] = &t
+	}
+	return nil
+}
+
 func (m *MapStorage) BlockExists(hash string) (bool, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -52,3 +79,89 @@ func (m *MapStorage) BlockAdd(hash string, txs []string) error {
 	m.blocks[hash] = txs
 	return nil
 }
+
+func (m *MapStorage) BlockDelete(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blocks, hash)
+	return nil
+}
+
+func (m *MapStorage) BlockStatsGet(hash string) (*block.Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.blocksStats[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &b, nil
+}
+
+func (m *MapStorage) BlockStatsAdd(b block.Block) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocksStats[b.Hash] = b
+	return nil
+}
+
+func (m *MapStorage) BlockStatsDelete(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blocksStats, hash)
+	return nil
+}
+
+func (m *MapStorage) BlockStatsRange(from, to int) ([]block.Block, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]block.Block, 0)
+	for _, b := range m.blocksStats {
+		if b.Height >= from && b.Height <= to {
+			res = append(res, b)
+		}
+	}
+	// same as the redis index
+	sort.Slice(res, func(i, j int) bool { return res[i].Height < res[j].Height })
+	return res, nil
+}
+
+func (m *MapStorage) BlockHealthGet(hash string) (*block.Health, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.health[hash]
+	if !ok {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+func (m *MapStorage) BlockHealthAdd(h block.Health) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.health[h.Hash] = h
+	return nil
+}
+
+func (m *MapStorage) BlockHealthDelete(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.health, hash)
+	return nil
+}
+
+func (m *MapStorage) BackfillGet() (*block.Backfill, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.backfill == nil {
+		return nil, nil
+	}
+	b := *m.backfill
+	return &b, nil
+}
+
+func (m *MapStorage) BackfillSet(b block.Backfill) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.backfill = &b
+	return nil
+}
@@ -0,0 +1,11 @@
+package storage_map
+
+import (
+	"testing"
+
+	"github.com/1F47E/go-feesh/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, New())
+}
@@ -3,51 +3,293 @@ package storage_redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
 
+	"github.com/1F47E/go-feesh/entity/models/block"
 	"github.com/1F47E/go-feesh/entity/models/tx"
 
 	redis "github.com/redis/go-redis/v9"
 )
 
+// keys, all prefixed
+const (
+	keyTx         = "tx:"         // parsed tx json, expires
+	keyBlock      = "block:"      // block txids list, coinbase first
+	keyBlocks     = "blocks"      // set of the stored block hashes
+	keyStats      = "blockstats:" // computed block stats json
+	keyStatsIndex = "blockstats"  // sorted set of the stats hashes by height
+	keyHealth     = "health:"     // block health json
+	keyBackfill   = "backfill"    // backfill progress json
+)
+
+// keys per MGET, big pool is read in chunks
+const mgetChunk = 5000
+
+type Options struct {
+	Addr     string
+	Password string
+	DB       int
+	Prefix   string
+	// mempool txs are evicted by the node after 2 weeks by default,
+	// confirmed ones are not needed after the block is processed
+	TxTTL time.Duration
+}
+
 type Redis struct {
-	ctx context.Context
-	db  *redis.Client
+	ctx    context.Context
+	db     *redis.Client
+	prefix string
+	txTTL  time.Duration
 }
 
-func New(ctx context.Context) (*Redis, error) {
+func New(ctx context.Context, opts Options) (*Redis, error) {
 	r := Redis{
 		ctx: ctx,
 		db: redis.NewClient(&redis.Options{
-			Addr:     "localhost:6379",
-			Password: "",
-			DB:       0,
+			Addr:     opts.Addr,
+			Password: opts.Password,
+			DB:       opts.DB,
 		}),
+		prefix: opts.Prefix,
+		txTTL:  opts.TxTTL,
 	}
 	// check connection
-	err := r.db.Ping(context.Background()).Err()
+	err := r.db.Ping(ctx).Err()
 	if err != nil {
 		return nil, err
 	}
 	return &r, nil
 }
 
+func (r *Redis) Close() error {
+	return r.db.Close()
+}
+
+func (r *Redis) key(parts ...string) string {
+	ret := r.prefix
+	for _, p := range parts {
+		ret += p
+	}
+	return ret
+}
+
+// json value of the key, false if there is none
+func (r *Redis) getJson(key string, ret interface{}) (bool, error) {
+	data, err := r.db.Get(r.ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, ret)
+}
+
 func (r *Redis) TxGet(txid string) (*tx.Tx, error) {
-	val, err := r.db.Get(r.ctx, txid).Result()
+	var t tx.Tx
+	ok, err := r.getJson(r.key(keyTx, txid), &t)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *Redis) TxGetMany(txids []string) ([]*tx.Tx, error) {
+	ret := make([]*tx.Tx, 0, len(txids))
+	for len(txids) > 0 {
+		n := mgetChunk
+		if n > len(txids) {
+			n = len(txids)
+		}
+		keys := make([]string, n)
+		for i, txid := range txids[:n] {
+			keys[i] = r.key(keyTx, txid)
+		}
+		vals, err := r.db.MGet(r.ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vals {
+			s, ok := v.(string)
+			if !ok {
+				ret = append(ret, nil)
+				continue
+			}
+			var t tx.Tx
+			if err := json.Unmarshal([]byte(s), &t); err != nil {
+				return nil, err
+			}
+			ret = append(ret, &t)
+		}
+		txids = txids[n:]
+	}
+	return ret, nil
+}
+
+func (r *Redis) TxAdd(t tx.Tx) error {
+	return r.TxAddMany([]tx.Tx{t})
+}
+
+// single pipeline for all the txs
+func (r *Redis) TxAddMany(txs []tx.Tx) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	_, err := r.db.Pipelined(r.ctx, func(p redis.Pipeliner) error {
+		for _, t := range txs {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			p.Set(r.ctx, r.key(keyTx, t.Hash), data, r.txTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) BlockExists(hash string) (bool, error) {
+	return r.db.SIsMember(r.ctx, r.key(keyBlocks), hash).Result()
+}
+
+func (r *Redis) BlockGet(hash string) ([]string, error) {
+	txs, err := r.db.LRange(r.ctx, r.key(keyBlock, hash), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(txs) == 0 {
+		return nil, nil
+	}
+	return txs, nil
+}
+
+// replaces the block txs
+func (r *Redis) BlockAdd(hash string, txs []string) error {
+	_, err := r.db.TxPipelined(r.ctx, func(p redis.Pipeliner) error {
+		p.Del(r.ctx, r.key(keyBlock, hash))
+		if len(txs) > 0 {
+			vals := make([]interface{}, len(txs))
+			for i, txid := range txs {
+				vals[i] = txid
+			}
+			p.RPush(r.ctx, r.key(keyBlock, hash), vals...)
+		}
+		p.SAdd(r.ctx, r.key(keyBlocks), hash)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) BlockDelete(hash string) error {
+	_, err := r.db.TxPipelined(r.ctx, func(p redis.Pipeliner) error {
+		p.Del(r.ctx, r.key(keyBlock, hash))
+		p.SRem(r.ctx, r.key(keyBlocks), hash)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) BlockStatsGet(hash string) (*block.Block, error) {
+	var b block.Block
+	ok, err := r.getJson(r.key(keyStats, hash), &b)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *Redis) BlockStatsAdd(b block.Block) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.TxPipelined(r.ctx, func(p redis.Pipeliner) error {
+		p.Set(r.ctx, r.key(keyStats, b.Hash), data, 0)
+		p.ZAdd(r.ctx, r.key(keyStatsIndex), redis.Z{Score: float64(b.Height), Member: b.Hash})
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) BlockStatsDelete(hash string) error {
+	_, err := r.db.TxPipelined(r.ctx, func(p redis.Pipeliner) error {
+		p.Del(r.ctx, r.key(keyStats, hash))
+		p.ZRem(r.ctx, r.key(keyStatsIndex), hash)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) BlockStatsRange(from, to int) ([]block.Block, error) {
+	hashes, err := r.db.ZRangeByScore(r.ctx, r.key(keyStatsIndex), &redis.ZRangeBy{
+		Min: strconv.Itoa(from),
+		Max: strconv.Itoa(to),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]block.Block, 0, len(hashes))
+	if len(hashes) == 0 {
+		return res, nil
+	}
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = r.key(keyStats, hash)
+	}
+	vals, err := r.db.MGet(r.ctx, keys...).Result()
 	if err != nil {
 		return nil, err
 	}
-	var tx tx.Tx
-	err = json.Unmarshal([]byte(val), &tx)
+	for _, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var b block.Block
+		if err := json.Unmarshal([]byte(s), &b); err != nil {
+			return nil, err
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+func (r *Redis) BlockHealthGet(hash string) (*block.Health, error) {
+	var h block.Health
+	ok, err := r.getJson(r.key(keyHealth, hash), &h)
+	if !ok || err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (r *Redis) BlockHealthAdd(h block.Health) error {
+	data, err := json.Marshal(h)
 	if err != nil {
+		return err
+	}
+	return r.db.Set(r.ctx, r.key(keyHealth, h.Hash), data, 0).Err()
+}
+
+func (r *Redis) BlockHealthDelete(hash string) error {
+	return r.db.Del(r.ctx, r.key(keyHealth, hash)).Err()
+}
+
+func (r *Redis) BackfillGet() (*block.Backfill, error) {
+	var b block.Backfill
+	ok, err := r.getJson(r.key(keyBackfill), &b)
+	if !ok || err != nil {
 		return nil, err
 	}
-	return &tx, nil
+	return &b, nil
 }
 
-func (r *Redis) TxAdd(tx *tx.Tx) error {
-	data, err := json.Marshal(tx)
+func (r *Redis) BackfillSet(b block.Backfill) error {
+	data, err := json.Marshal(b)
 	if err != nil {
 		return err
 	}
-	return r.db.Set(r.ctx, tx.Hash, data, 0).Err()
+	return r.db.Set(r.ctx, r.key(keyBackfill), data, 0).Err()
 }
@@ -0,0 +1,62 @@
+package storage_redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+	"github.com/1F47E/go-feesh/storage/storagetest"
+)
+
+// local redis, REDIS_ADDR to use another one. skipped if there is none
+func newTestRedis(t *testing.T) *Redis {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Skipf("no redis at %s: %v", addr, err)
+	}
+	conn.Close()
+	ctx := context.Background()
+	// own prefix, so the test does not touch the real data
+	prefix := fmt.Sprintf("feesh-test-%d:", time.Now().UnixNano())
+	r, err := New(ctx, Options{
+		Addr:     addr,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		Prefix:   prefix,
+		TxTTL:    time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+	t.Cleanup(func() {
+		iter := r.db.Scan(ctx, 0, prefix+"*", 1000).Iterator()
+		for iter.Next(ctx) {
+			r.db.Del(ctx, iter.Val())
+		}
+		r.Close()
+	})
+	return r
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, newTestRedis(t))
+}
+
+func TestTxTTL(t *testing.T) {
+	r := newTestRedis(t)
+	if err := r.TxAdd(mtx.Tx{Hash: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	ttl, err := r.db.TTL(r.ctx, r.key(keyTx, "a")).Result()
+	if err != nil || ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("tx ttl %v, %v", ttl, err)
+	}
+}
@@ -1,13 +1,33 @@
 package storage
 
 import (
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
 	mtx "github.com/1F47E/go-feesh/entity/models/tx"
 )
 
 type PoolRepository interface {
 	TxGet(txid string) (*mtx.Tx, error)
+	// same order as the txids, nil if not found
+	TxGetMany(txids []string) ([]*mtx.Tx, error)
 	TxAdd(tx mtx.Tx) error
+	TxAddMany(txs []mtx.Tx) error
 	BlockExists(hash string) (bool, error)
 	BlockGet(hash string) ([]string, error)
 	BlockAdd(hash string, txs []string) error
+	BlockDelete(hash string) error
+	// computed block stats, persistent
+	BlockStatsGet(hash string) (*mblock.Block, error)
+	BlockStatsAdd(b mblock.Block) error
+	// stats of the orphaned block, no-op if there are none
+	BlockStatsDelete(hash string) error
+	// block stats by height, inclusive
+	BlockStatsRange(from, to int) ([]mblock.Block, error)
+	// mined tip vs the expected template
+	BlockHealthGet(hash string) (*mblock.Health, error)
+	BlockHealthAdd(h mblock.Health) error
+	// health of the orphaned block, no-op if there is none
+	BlockHealthDelete(hash string) error
+	// historical blocks backfill progress
+	BackfillGet() (*mblock.Backfill, error)
+	BackfillSet(b mblock.Backfill) error
 }
@@ -0,0 +1,309 @@
+// package storagetest is the behaviour every PoolRepository backend has to share.
+// backends run it from their own tests over an empty repository
+package storagetest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	mblock "github.com/1F47E/go-feesh/entity/models/block"
+	mtx "github.com/1F47E/go-feesh/entity/models/tx"
+	"github.com/1F47E/go-feesh/storage"
+)
+
+// more than a single redis MGET chunk
+const manyTxs = 12_000
+
+// json round trip keeps the time in utc without monotonic part
+var testTime = time.Unix(1_700_000_000, 0).UTC()
+
+// run the conformance tests, repo has to be empty
+func Run(t *testing.T, repo storage.PoolRepository) {
+	t.Run("Tx", func(t *testing.T) { testTx(t, repo) })
+	t.Run("TxMany", func(t *testing.T) { testTxMany(t, repo) })
+	t.Run("Block", func(t *testing.T) { testBlock(t, repo) })
+	t.Run("BlockStats", func(t *testing.T) { testBlockStats(t, repo) })
+	t.Run("BlockHealth", func(t *testing.T) { testBlockHealth(t, repo) })
+	t.Run("Backfill", func(t *testing.T) { testBackfill(t, repo) })
+}
+
+func txid(n int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+func newTx(n int) mtx.Tx {
+	return mtx.Tx{
+		Hash:          txid(n),
+		Time:          testTime,
+		Size:          200,
+		Weight:        800,
+		Fee:           uint64(1000 + n),
+		AmountIn:      100_000,
+		AmountOut:     99_000 - uint64(n),
+		Segwit:        true,
+		Depends:       []string{txid(n + 1)},
+		Spends:        []string{txid(n+1) + ":0"},
+		AncestorCount: 2,
+		AncestorFees:  2000,
+	}
+}
+
+func testTx(t *testing.T, repo storage.PoolRepository) {
+	got, err := repo.TxGet(txid(1))
+	if err != nil || got != nil {
+		t.Fatalf("missing tx: %+v, %v", got, err)
+	}
+	want := newTx(1)
+	if err := repo.TxAdd(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.TxGet(want.Hash)
+	if err != nil || got == nil || !reflect.DeepEqual(*got, want) {
+		t.Fatalf("tx %+v, %v, want %+v", got, err, want)
+	}
+	// parsed again, replaced
+	want.Fee = 1
+	if err := repo.TxAdd(want); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = repo.TxGet(want.Hash)
+	if got == nil || got.Fee != 1 {
+		t.Fatalf("replaced tx %+v", got)
+	}
+}
+
+func testTxMany(t *testing.T, repo storage.PoolRepository) {
+	if err := repo.TxAddMany(nil); err != nil {
+		t.Fatalf("empty add: %v", err)
+	}
+	txs := make([]mtx.Tx, manyTxs)
+	for i := range txs {
+		txs[i] = newTx(100 + i)
+	}
+	if err := repo.TxAddMany(txs); err != nil {
+		t.Fatal(err)
+	}
+	// reversed, with missing ones in between
+	txids := make([]string, 0, 2*manyTxs)
+	for i := manyTxs - 1; i >= 0; i-- {
+		txids = append(txids, txs[i].Hash, txid(1_000_000+i))
+	}
+	got, err := repo.TxGetMany(txids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(txids) {
+		t.Fatalf("got %d txs, want %d", len(got), len(txids))
+	}
+	for i, tx := range got {
+		if i%2 == 1 {
+			if tx != nil {
+				t.Fatalf("%d: missing tx %s returned as %+v", i, txids[i], tx)
+			}
+			continue
+		}
+		if tx == nil || tx.Hash != txids[i] || !reflect.DeepEqual(*tx, txs[manyTxs-1-i/2]) {
+			t.Fatalf("%d: tx %+v, want %s", i, tx, txids[i])
+		}
+	}
+	got, err = repo.TxGetMany(nil)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("empty get: %v, %v", got, err)
+	}
+}
+
+func testBlock(t *testing.T, repo storage.PoolRepository) {
+	hash := txid(2_000_000)
+	exists, err := repo.BlockExists(hash)
+	if err != nil || exists {
+		t.Fatalf("missing block exists: %v, %v", exists, err)
+	}
+	txs, err := repo.BlockGet(hash)
+	if err != nil || len(txs) != 0 {
+		t.Fatalf("missing block txs: %v, %v", txs, err)
+	}
+	// coinbase first, order is kept
+	want := []string{txid(3), txid(1), txid(2)}
+	if err := repo.BlockAdd(hash, want); err != nil {
+		t.Fatal(err)
+	}
+	exists, err = repo.BlockExists(hash)
+	if err != nil || !exists {
+		t.Fatalf("block exists: %v, %v", exists, err)
+	}
+	txs, err = repo.BlockGet(hash)
+	if err != nil || !reflect.DeepEqual(txs, want) {
+		t.Fatalf("block txs %v, %v, want %v", txs, err, want)
+	}
+	// stored again, replaced
+	want = want[:1]
+	if err := repo.BlockAdd(hash, want); err != nil {
+		t.Fatal(err)
+	}
+	txs, _ = repo.BlockGet(hash)
+	if !reflect.DeepEqual(txs, want) {
+		t.Fatalf("replaced block txs %v, want %v", txs, want)
+	}
+
+	if err := repo.BlockDelete(hash); err != nil {
+		t.Fatal(err)
+	}
+	exists, _ = repo.BlockExists(hash)
+	txs, _ = repo.BlockGet(hash)
+	if exists || len(txs) != 0 {
+		t.Fatalf("deleted block: exists %v, txs %v", exists, txs)
+	}
+	if err := repo.BlockDelete(hash); err != nil {
+		t.Fatalf("delete missing block: %v", err)
+	}
+}
+
+func newStats(height int) mblock.Block {
+	return mblock.Block{
+		Hash:          txid(3_000_000 + height),
+		Height:        height,
+		Time:          testTime.Unix() + int64(height)*600,
+		Interval:      600,
+		Pool:          "pool",
+		Fee:           uint64(height) * 1000,
+		Subsidy:       625_000_000,
+		Txs:           3,
+		TxsParsed:     3,
+		FeeRateMedian: 12.5,
+		SegwitShare:   0.5,
+	}
+}
+
+func testBlockStats(t *testing.T, repo storage.PoolRepository) {
+	got, err := repo.BlockStatsGet(txid(3_000_100))
+	if err != nil || got != nil {
+		t.Fatalf("missing stats: %+v, %v", got, err)
+	}
+	// added out of order
+	for _, height := range []int{103, 100, 102, 101, 104} {
+		if err := repo.BlockStatsAdd(newStats(height)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := newStats(101)
+	got, err = repo.BlockStatsGet(want.Hash)
+	if err != nil || got == nil || !reflect.DeepEqual(*got, want) {
+		t.Fatalf("stats %+v, %v, want %+v", got, err, want)
+	}
+	// updated, not duplicated in the range
+	want.Fee = 1
+	if err := repo.BlockStatsAdd(want); err != nil {
+		t.Fatal(err)
+	}
+
+	// inclusive, ordered by height
+	res, err := repo.BlockStatsRange(101, 103)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("range has %d blocks, want 3: %+v", len(res), res)
+	}
+	for i, b := range res {
+		if b.Height != 101+i {
+			t.Fatalf("%d: height %d, want %d", i, b.Height, 101+i)
+		}
+	}
+	if !reflect.DeepEqual(res[0], want) {
+		t.Fatalf("updated stats %+v, want %+v", res[0], want)
+	}
+	res, err = repo.BlockStatsRange(200, 300)
+	if err != nil || res == nil || len(res) != 0 {
+		t.Fatalf("empty range: %v, %v", res, err)
+	}
+
+	// orphaned block, gone from the range too
+	if err := repo.BlockStatsDelete(want.Hash); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.BlockStatsGet(want.Hash)
+	if err != nil || got != nil {
+		t.Fatalf("deleted stats: %+v, %v", got, err)
+	}
+	res, _ = repo.BlockStatsRange(100, 104)
+	if len(res) != 4 {
+		t.Fatalf("range has %d blocks after delete, want 4", len(res))
+	}
+	for _, b := range res {
+		if b.Hash == want.Hash {
+			t.Fatalf("deleted stats in range: %+v", b)
+		}
+	}
+	if err := repo.BlockStatsDelete(want.Hash); err != nil {
+		t.Fatalf("delete missing stats: %v", err)
+	}
+}
+
+func testBlockHealth(t *testing.T, repo storage.PoolRepository) {
+	hash := txid(4_000_000)
+	got, err := repo.BlockHealthGet(hash)
+	if err != nil || got != nil {
+		t.Fatalf("missing health: %+v, %v", got, err)
+	}
+	want := mblock.Health{
+		Hash:         hash,
+		Height:       100,
+		Match:        66.6,
+		Expected:     3,
+		Mined:        3,
+		Matched:      2,
+		Missing:      []string{txid(1)},
+		Unexpected:   []string{txid(2)},
+		TemplateTime: testTime,
+		Time:         testTime.Add(time.Minute),
+	}
+	if err := repo.BlockHealthAdd(want); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.BlockHealthGet(hash)
+	if err != nil || got == nil || !reflect.DeepEqual(*got, want) {
+		t.Fatalf("health %+v, %v, want %+v", got, err, want)
+	}
+
+	// orphaned block
+	if err := repo.BlockHealthDelete(hash); err != nil {
+		t.Fatal(err)
+	}
+	got, err = repo.BlockHealthGet(hash)
+	if err != nil || got != nil {
+		t.Fatalf("deleted health: %+v, %v", got, err)
+	}
+	if err := repo.BlockHealthDelete(hash); err != nil {
+		t.Fatalf("delete missing health: %v", err)
+	}
+}
+
+func testBackfill(t *testing.T, repo storage.PoolRepository) {
+	got, err := repo.BackfillGet()
+	if err != nil || got != nil {
+		t.Fatalf("missing backfill: %+v, %v", got, err)
+	}
+	want := mblock.Backfill{
+		Direction:    mblock.BackfillBackward,
+		StartHeight:  1000,
+		TargetHeight: 900,
+		NextHeight:   950,
+		Done:         50,
+		Total:        100,
+		StartedAt:    testTime,
+		UpdatedAt:    testTime.Add(time.Hour),
+		EtaSeconds:   3600,
+	}
+	for _, done := range []int{10, 50} {
+		want.Done = done
+		if err := repo.BackfillSet(want); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err = repo.BackfillGet()
+	if err != nil || got == nil || !reflect.DeepEqual(*got, want) {
+		t.Fatalf("backfill %+v, %v, want %+v", got, err, want)
+	}
+}
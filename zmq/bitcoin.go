@@ -0,0 +1,63 @@
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// bitcoin core topics
+const (
+	TopicRawTx     = "rawtx"
+	TopicHashBlock = "hashblock"
+	TopicSequence  = "sequence"
+)
+
+// sequence topic labels
+const (
+	SequenceBlockConnect    = 'C'
+	SequenceBlockDisconnect = 'D'
+	SequenceTxAdded         = 'A'
+	SequenceTxRemoved       = 'R'
+)
+
+// sequence message: hash, label and the mempool sequence for the tx events
+type Sequence struct {
+	Hash       string
+	Label      byte
+	MempoolSeq uint64
+}
+
+func ParseSequence(body []byte) (Sequence, error) {
+	if len(body) < 33 {
+		return Sequence{}, fmt.Errorf("sequence message is too short: %d", len(body))
+	}
+	s := Sequence{
+		Hash:  hex.EncodeToString(body[:32]),
+		Label: body[32],
+	}
+	if (s.Label == SequenceTxAdded || s.Label == SequenceTxRemoved) && len(body) >= 41 {
+		s.MempoolSeq = binary.LittleEndian.Uint64(body[33:41])
+	}
+	return s, nil
+}
+
+// hashes are sent in the RPC byte order
+func ParseHash(body []byte) (string, error) {
+	if len(body) != 32 {
+		return "", fmt.Errorf("invalid hash length: %d", len(body))
+	}
+	return hex.EncodeToString(body), nil
+}
+
+// txid of the serialized tx
+func RawTxid(body []byte) (string, error) {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(body)); err != nil {
+		return "", err
+	}
+	return tx.TxHash().String(), nil
+}
@@ -0,0 +1,139 @@
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// PUB socket stand-in for the local testing without the node.
+// messages are sent the same way as bitcoin core does: topic, body, sequence
+type Publisher struct {
+	ln   net.Listener
+	mu   *sync.Mutex
+	subs map[net.Conn]*pubSub
+	seq  map[string]uint32
+}
+
+type pubSub struct {
+	mu     *sync.Mutex
+	topics [][]byte
+}
+
+func NewPublisher(addr string) (*Publisher, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Publisher{
+		ln:   ln,
+		mu:   &sync.Mutex{},
+		subs: make(map[net.Conn]*pubSub),
+		seq:  make(map[string]uint32),
+	}
+	go p.accept()
+	return p, nil
+}
+
+func (p *Publisher) Addr() string {
+	return "tcp://" + p.ln.Addr().String()
+}
+
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	for conn := range p.subs {
+		conn.Close()
+	}
+	p.mu.Unlock()
+	return p.ln.Close()
+}
+
+func (p *Publisher) accept() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.serve(conn)
+	}
+}
+
+func (p *Publisher) serve(conn net.Conn) {
+	log := logger.Log.WithField("context", "[ZMQ PUB]")
+	r := bufio.NewReader(conn)
+	if err := handshake(r, conn, "PUB"); err != nil {
+		log.Errorf("handshake: %v\n", err)
+		conn.Close()
+		return
+	}
+	sub := &pubSub{mu: &sync.Mutex{}}
+	p.mu.Lock()
+	p.subs[conn] = sub
+	p.mu.Unlock()
+	defer p.drop(conn)
+
+	// subscriptions, 0x01 to subscribe and 0x00 to unsubscribe
+	for {
+		body, flags, err := readFrame(r)
+		if err != nil {
+			return
+		}
+		if flags&flagCommand != 0 || len(body) == 0 {
+			continue
+		}
+		topic := body[1:]
+		sub.mu.Lock()
+		switch body[0] {
+		case 1:
+			sub.topics = append(sub.topics, topic)
+		case 0:
+			for i, t := range sub.topics {
+				if bytes.Equal(t, topic) {
+					sub.topics = append(sub.topics[:i], sub.topics[i+1:]...)
+					break
+				}
+			}
+		}
+		sub.mu.Unlock()
+	}
+}
+
+func (p *Publisher) drop(conn net.Conn) {
+	p.mu.Lock()
+	delete(p.subs, conn)
+	p.mu.Unlock()
+	conn.Close()
+}
+
+// send to all the subscribers of the topic prefix
+func (p *Publisher) Publish(topic string, body []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	seq := make([]byte, 4)
+	binary.LittleEndian.PutUint32(seq, p.seq[topic])
+	p.seq[topic]++
+	for conn, sub := range p.subs {
+		if !sub.match(topic) {
+			continue
+		}
+		if err := writeMessage(conn, []byte(topic), body, seq); err != nil {
+			delete(p.subs, conn)
+			conn.Close()
+		}
+	}
+}
+
+func (s *pubSub) match(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.topics {
+		if bytes.HasPrefix([]byte(topic), t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package zmq
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/1F47E/go-feesh/logger"
+)
+
+// pause before reconnect
+var reconnectDelay = 5 * time.Second
+
+type Msg struct {
+	Topic string
+	Body  []byte
+	Seq   uint32 // per topic sequence, gaps mean lost messages
+}
+
+// SUB socket, reconnects until the context is done
+type Subscriber struct {
+	addr   string
+	topics []string
+	// connection state changes, optional
+	OnState func(connected bool)
+}
+
+// address as in bitcoin core config, tcp://127.0.0.1:28332
+func NewSubscriber(addr string, topics ...string) *Subscriber {
+	return &Subscriber{
+		addr:   strings.TrimPrefix(addr, "tcp://"),
+		topics: topics,
+	}
+}
+
+func (s *Subscriber) Run(ctx context.Context, handler func(Msg)) {
+	log := logger.Log.WithField("context", "[ZMQ]")
+	for {
+		err := s.run(ctx, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("subscriber error: %v, reconnecting in %s\n", err, reconnectDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (s *Subscriber) run(ctx context.Context, handler func(Msg)) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// unblock the read on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	if err := handshake(r, conn, "SUB"); err != nil {
+		return fmt.Errorf("handshake: %w", err)
+	}
+	// ZMTP 3.0 subscription is a message with 0x01 prefix
+	for _, topic := range s.topics {
+		if err := writeFrame(conn, append([]byte{1}, topic...), 0); err != nil {
+			return err
+		}
+	}
+	logger.Log.WithField("context", "[ZMQ]").Infof("subscribed to %s: %v\n", s.addr, s.topics)
+	if s.OnState != nil {
+		s.OnState(true)
+		defer s.OnState(false)
+	}
+
+	for {
+		parts, err := readMessage(r)
+		if err != nil {
+			return err
+		}
+		if len(parts) < 2 {
+			continue
+		}
+		msg := Msg{Topic: string(parts[0]), Body: parts[1]}
+		if len(parts) > 2 && len(parts[2]) == 4 {
+			msg.Seq = binary.LittleEndian.Uint32(parts[2])
+		}
+		handler(msg)
+	}
+}
@@ -0,0 +1,211 @@
+package zmq
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/1F47E/go-feesh/logger"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("DEBUG") != "1" {
+		logger.Log.SetLevel(logrus.ErrorLevel)
+	}
+	reconnectDelay = 10 * time.Millisecond
+	os.Exit(m.Run())
+}
+
+// subscriber over the publisher, messages and states are sent to the channels
+func newTestSub(t *testing.T, addr string) (chan Msg, chan bool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := make(chan Msg, 100)
+	states := make(chan bool, 100)
+	s := NewSubscriber(addr, TopicRawTx, TopicHashBlock, TopicSequence)
+	s.OnState = func(connected bool) { states <- connected }
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, func(m Msg) { msgs <- m })
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return msgs, states
+}
+
+func waitState(t *testing.T, states chan bool, want bool) {
+	t.Helper()
+	select {
+	case got := <-states:
+		if got != want {
+			t.Fatalf("connected %v, want %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no state change to %v", want)
+	}
+}
+
+// subscriptions are sent before the connected state, but handled by the publisher async
+func waitSubscribed(t *testing.T, p *Publisher, topics int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		n := 0
+		p.mu.Lock()
+		for _, sub := range p.subs {
+			sub.mu.Lock()
+			n += len(sub.topics)
+			sub.mu.Unlock()
+		}
+		p.mu.Unlock()
+		if n == topics {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("publisher has no %d subscriptions", topics)
+}
+
+func nextMsg(t *testing.T, msgs chan Msg) Msg {
+	t.Helper()
+	select {
+	case m := <-msgs:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+	}
+	return Msg{}
+}
+
+// tx with the script big enough for the long frame
+func testRawTx(t *testing.T) ([]byte, string) {
+	t.Helper()
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{1}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, bytes.Repeat([]byte{0x51}, 300)))
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), tx.TxHash().String()
+}
+
+func TestSubscriber(t *testing.T) {
+	p, err := NewPublisher("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	msgs, states := newTestSub(t, p.Addr())
+	waitState(t, states, true)
+	waitSubscribed(t, p, 3)
+
+	raw, txid := testRawTx(t)
+	hash := bytes.Repeat([]byte{0xab}, 32)
+	seq := append(append([]byte{}, hash...), SequenceTxAdded)
+	seq = binary.LittleEndian.AppendUint64(seq, 42)
+
+	// not subscribed, skipped by the publisher
+	p.Publish("rawblock", []byte{1})
+	p.Publish(TopicRawTx, raw)
+	p.Publish(TopicHashBlock, hash)
+	p.Publish(TopicSequence, seq)
+	p.Publish(TopicRawTx, raw)
+
+	m := nextMsg(t, msgs)
+	if m.Topic != TopicRawTx || m.Seq != 0 {
+		t.Fatalf("first message %s seq %d, want rawtx 0", m.Topic, m.Seq)
+	}
+	if got, err := RawTxid(m.Body); err != nil || got != txid {
+		t.Fatalf("rawtx txid %s, %v, want %s", got, err, txid)
+	}
+
+	m = nextMsg(t, msgs)
+	if got, err := ParseHash(m.Body); m.Topic != TopicHashBlock || err != nil || got != hex.EncodeToString(hash) {
+		t.Fatalf("hashblock %s: %s, %v", m.Topic, got, err)
+	}
+
+	m = nextMsg(t, msgs)
+	s, err := ParseSequence(m.Body)
+	if m.Topic != TopicSequence || err != nil {
+		t.Fatalf("sequence %s: %v", m.Topic, err)
+	}
+	if s.Hash != hex.EncodeToString(hash) || s.Label != SequenceTxAdded || s.MempoolSeq != 42 {
+		t.Fatalf("sequence %+v", s)
+	}
+
+	// per topic sequence
+	m = nextMsg(t, msgs)
+	if m.Topic != TopicRawTx || m.Seq != 1 {
+		t.Fatalf("message %s seq %d, want rawtx 1", m.Topic, m.Seq)
+	}
+}
+
+// node restarted on the same address
+func TestSubscriberReconnect(t *testing.T) {
+	p, err := NewPublisher("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := p.Addr()
+	msgs, states := newTestSub(t, addr)
+	waitState(t, states, true)
+	waitSubscribed(t, p, 3)
+
+	p.Close()
+	waitState(t, states, false)
+
+	p, err = NewPublisher(strings.TrimPrefix(addr, "tcp://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	waitState(t, states, true)
+	waitSubscribed(t, p, 3)
+
+	hash := bytes.Repeat([]byte{0xcd}, 32)
+	p.Publish(TopicHashBlock, hash)
+	m := nextMsg(t, msgs)
+	if got, _ := ParseHash(m.Body); m.Topic != TopicHashBlock || got != hex.EncodeToString(hash) {
+		t.Fatalf("message after reconnect %s: %x", m.Topic, m.Body)
+	}
+}
+
+// not a ZMTP peer
+func TestSubscriberBadGreeting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write(make([]byte, 64))
+		_, _ = conn.Read(make([]byte, 64))
+	}()
+
+	s := NewSubscriber("tcp://"+ln.Addr().String(), TopicRawTx)
+	s.OnState = func(connected bool) { t.Fatal("connected to not a ZMTP peer") }
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = s.run(ctx, func(Msg) {})
+	if err == nil || !strings.Contains(err.Error(), "invalid greeting") {
+		t.Fatalf("err %v, want invalid greeting", err)
+	}
+}
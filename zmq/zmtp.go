@@ -0,0 +1,150 @@
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// minimal ZMTP 3.0 with NULL security, enough for PUB/SUB with bitcoin core
+// https://rfc.zeromq.org/spec/23/
+
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// frames bigger than this are considered broken, blocks are 4Mb max
+const maxFrameSize = 64 << 20
+
+func greeting() []byte {
+	g := make([]byte, 64)
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = 3 // version 3.0
+	g[11] = 0
+	copy(g[12:32], "NULL")
+	return g
+}
+
+// send our greeting and READY, check the peer ones
+func handshake(r io.Reader, w io.Writer, socketType string) error {
+	if _, err := w.Write(greeting()); err != nil {
+		return err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(r, peer); err != nil {
+		return err
+	}
+	if peer[0] != 0xff || peer[9] != 0x7f {
+		return fmt.Errorf("invalid greeting signature")
+	}
+	if peer[10] < 3 {
+		return fmt.Errorf("unsupported ZMTP version %d.%d", peer[10], peer[11])
+	}
+	if mech := string(bytes.TrimRight(peer[12:32], "\x00")); mech != "NULL" {
+		return fmt.Errorf("unsupported security mechanism %s", mech)
+	}
+
+	if err := writeFrame(w, readyCommand(socketType), flagCommand); err != nil {
+		return err
+	}
+	body, flags, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	if flags&flagCommand == 0 || !bytes.HasPrefix(body, []byte("\x05READY")) {
+		return fmt.Errorf("expected READY command")
+	}
+	return nil
+}
+
+func readyCommand(socketType string) []byte {
+	var b bytes.Buffer
+	b.WriteByte(5)
+	b.WriteString("READY")
+	name := "Socket-Type"
+	b.WriteByte(byte(len(name)))
+	b.WriteString(name)
+	_ = binary.Write(&b, binary.BigEndian, uint32(len(socketType)))
+	b.WriteString(socketType)
+	return b.Bytes()
+}
+
+func writeFrame(w io.Writer, body []byte, flags byte) error {
+	var hdr []byte
+	if len(body) > 255 {
+		hdr = make([]byte, 9)
+		hdr[0] = flags | flagLong
+		binary.BigEndian.PutUint64(hdr[1:], uint64(len(body)))
+	} else {
+		hdr = []byte{flags, byte(len(body))}
+	}
+	if _, err := w.Write(append(hdr, body...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readFrame(r io.Reader) ([]byte, byte, error) {
+	hdr := make([]byte, 1)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, 0, err
+	}
+	flags := hdr[0]
+	var size uint64
+	if flags&flagLong != 0 {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, 0, err
+		}
+		size = binary.BigEndian.Uint64(buf)
+	} else {
+		buf := make([]byte, 1)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, 0, err
+		}
+		size = uint64(buf[0])
+	}
+	if size > maxFrameSize {
+		return nil, 0, fmt.Errorf("frame is too big: %d", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, err
+	}
+	return body, flags, nil
+}
+
+// multipart message, commands are skipped
+func readMessage(r io.Reader) ([][]byte, error) {
+	parts := make([][]byte, 0, 3)
+	for {
+		body, flags, err := readFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			continue
+		}
+		parts = append(parts, body)
+		if flags&flagMore == 0 {
+			return parts, nil
+		}
+	}
+}
+
+func writeMessage(w io.Writer, parts ...[]byte) error {
+	for i, p := range parts {
+		var flags byte
+		if i < len(parts)-1 {
+			flags = flagMore
+		}
+		if err := writeFrame(w, p, flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}